@@ -0,0 +1,187 @@
+package blocks
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"swaystats/clicks"
+	"swaystats/metrics"
+	"swaystats/stats"
+)
+
+// staleMarker is appended to an async block whose sample overran its timeout.
+const staleMarker = "…"
+
+// closeWait bounds how long Close waits for the worker (and a plugin it
+// stops) to finish; a worker stuck in a sample is abandoned.
+const closeWait = 2 * time.Second
+
+// AsyncProvider runs a provider on its own goroutine. The render loop only
+// posts ticks and reads the last published Block, so a hanging read (NFS
+// statfs, a stuck script, a D-Bus call) never stalls the bar. While a sample
+// runs longer than the timeout the block is shown with a stale marker.
+//
+// The wrapped provider is built on the worker goroutine as well, since
+// constructors take a first sample.
+type AsyncProvider struct {
+	name, instance string
+	timeout        int64 // ns
+	ticks          chan int64
+	jobs           chan func(Provider)
+	done           chan struct{}
+	exited         chan struct{} // closed when the worker returns
+	closeOnce      sync.Once
+	state          atomic.Pointer[asyncState]
+	busySince      atomic.Int64 // unix ns the running call started, 0 when idle
+
+	// render goroutine only
+	seen  uint64
+	stale bool
+}
+
+// asyncState is what the worker publishes after each call.
+type asyncState struct {
+	blk     Block
+	metrics []metrics.Sample
+	version uint64 // bumped whenever blk changes
+}
+
+func NewAsyncProvider(name, instance string, timeout time.Duration, build func() Provider) *AsyncProvider {
+	a := &AsyncProvider{
+		name:     name,
+		instance: instance,
+		timeout:  int64(timeout),
+		ticks:    make(chan int64, 1),
+		jobs:     make(chan func(Provider), 8),
+		done:     make(chan struct{}),
+		exited:   make(chan struct{}),
+	}
+	a.state.Store(&asyncState{blk: Block{Name: name, Instance: instance, FullText: name + staleMarker, SeparatorBlockWidth: SeparatorWidth}})
+	go a.run(build)
+	return a
+}
+
+func (a *AsyncProvider) run(build func() Provider) {
+	var inner Provider
+	defer close(a.exited)
+	defer func() {
+		// Plugins own processes; stop them with the worker.
+		if c, ok := inner.(Closer); ok {
+			c.Close()
+		}
+	}()
+	a.call(func() { inner = build() })
+	a.publish(inner)
+	for {
+		select {
+		case <-a.done:
+			return
+		case now := <-a.ticks:
+			var changed bool
+			took := a.call(func() { changed = inner.MaybeRefresh(now) })
+			stats.Default.Observe(a.name, a.instance, took, changed)
+			a.publish(inner)
+		case job := <-a.jobs:
+			a.call(func() { job(inner) })
+			a.publish(inner)
+		}
+	}
+}
+
+// call runs f, exposing its start time to the render goroutine.
+func (a *AsyncProvider) call(f func()) time.Duration {
+	start := time.Now()
+	a.busySince.Store(start.UnixNano())
+	f()
+	a.busySince.Store(0)
+	return time.Since(start)
+}
+
+func (a *AsyncProvider) publish(inner Provider) {
+	prev := a.state.Load()
+	st := &asyncState{blk: inner.Current(), version: prev.version}
+	if st.blk != prev.blk {
+		st.version++
+	}
+	if mp, ok := inner.(MetricsProvider); ok {
+		st.metrics = mp.Metrics()
+	}
+	a.state.Store(st)
+}
+
+func (a *AsyncProvider) Name() string { return a.name }
+
+// MaybeRefresh posts a tick to the worker (dropped while it is busy) and
+// reports whether the published block or its staleness changed.
+func (a *AsyncProvider) MaybeRefresh(now int64) bool {
+	select {
+	case a.ticks <- now:
+	default:
+	}
+	st := a.state.Load()
+	busy := a.busySince.Load()
+	stale := busy != 0 && now-busy > a.timeout
+	changed := st.version != a.seen || stale != a.stale
+	a.seen, a.stale = st.version, stale
+	return changed
+}
+
+func (a *AsyncProvider) Current() Block {
+	blk := a.state.Load().blk
+	if a.stale {
+		blk.FullText += staleMarker
+	}
+	return blk
+}
+
+// HandleClick forwards the click to the worker; the result shows up on a
+// later tick, so it never reports a change itself.
+func (a *AsyncProvider) HandleClick(c clicks.Click, now int64) bool {
+	a.post(func(p Provider) {
+		if h, ok := p.(ClickHandler); ok {
+			h.HandleClick(c, now)
+		}
+	})
+	return false
+}
+
+func (a *AsyncProvider) Metrics() []metrics.Sample { return a.state.Load().metrics }
+
+// Warm waits (up to the timeout) until the provider is built and, if it is
+// a Warmer, has taken a real sample.
+func (a *AsyncProvider) Warm(now int64) {
+	done := make(chan struct{})
+	if !a.post(func(p Provider) {
+		if w, ok := p.(Warmer); ok {
+			w.Warm(now)
+		}
+		close(done)
+	}) {
+		return
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Duration(a.timeout)):
+	}
+}
+
+// Close stops the worker once its current call returns, closing the wrapped
+// provider if it is a Closer, and waits for that up to closeWait.
+func (a *AsyncProvider) Close() {
+	a.closeOnce.Do(func() { close(a.done) })
+	select {
+	case <-a.exited:
+	case <-time.After(closeWait):
+	}
+}
+
+// post queues job for the worker without blocking; false if the queue is full.
+func (a *AsyncProvider) post(job func(Provider)) bool {
+	select {
+	case a.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
@@ -1,5 +1,10 @@
 package blocks
 
+import (
+	"swaystats/clicks"
+	"swaystats/theme"
+)
+
 // Block represents an i3bar protocol block.
 // Only fields actually needed now; others can be added later.
 type Block struct {
@@ -13,6 +18,11 @@ type Block struct {
 	SeparatorBlockWidth int    `json:"separator_block_width,omitempty"`
 	Urgent              bool   `json:"urgent,omitempty"`
 	Markup              string `json:"markup,omitempty"`
+
+	// Not part of the i3bar protocol; used by other output backends.
+	Severity   theme.Severity `json:"-"`
+	Percentage int            `json:"-"` // rounded value of percentage-based blocks
+	Prefix     string         `json:"-"` // label FullText starts with, styled apart from the value (see Markup)
 }
 
 const SeparatorWidth = 12
@@ -25,3 +35,46 @@ type Provider interface {
 	MaybeRefresh(now int64) (changed bool)
 	Current() Block
 }
+
+// Warmer is implemented by providers whose first sample is only a baseline
+// (CPU usage is a delta between two reads). Warm takes a real sample now;
+// one-shot callers wait a moment after building providers and then call it.
+type Warmer interface {
+	Warm(now int64)
+}
+
+// Closer is implemented by providers that own goroutines or processes which
+// must be stopped when the provider is replaced (config reload).
+type Closer interface {
+	Close()
+}
+
+// CloseProviders closes every provider that implements Closer.
+func CloseProviders(providers []Provider) {
+	for _, p := range providers {
+		if c, ok := p.(Closer); ok {
+			c.Close()
+		}
+	}
+}
+
+// ClickHandler is implemented by providers that react to click events.
+// HandleClick runs on the render goroutine and returns true if Current() changed.
+type ClickHandler interface {
+	HandleClick(c clicks.Click, now int64) (changed bool)
+}
+
+// DispatchClick routes a click to the provider whose block name and instance match.
+// Returns true if that provider's block changed.
+func DispatchClick(providers []Provider, c clicks.Click, now int64) bool {
+	for _, p := range providers {
+		if p.Name() != c.Name || p.Current().Instance != c.Instance {
+			continue
+		}
+		if h, ok := p.(ClickHandler); ok {
+			return h.HandleClick(c, now)
+		}
+		return false
+	}
+	return false
+}
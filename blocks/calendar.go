@@ -0,0 +1,262 @@
+package blocks
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"swaystats/clicks"
+	"swaystats/config"
+	"swaystats/ical"
+	"swaystats/theme"
+)
+
+// CalendarProvider shows the next upcoming event from local iCalendar files
+// (single .ics files or vdir directories as synced by vdirsyncer).
+type CalendarProvider struct {
+	paths         []string
+	scanNs        int64 // interval between file change checks
+	lastScanNs    int64
+	lookahead     time.Duration
+	warn          time.Duration
+	danger        time.Duration
+	urgent        time.Duration
+	includeAllDay bool
+	maxLength     int
+	prefix        string
+	emptyText     string
+	onClick       string
+	instance      string
+
+	stamps  map[string]fileStamp // last seen state of every calendar file
+	events  []ical.Event
+	occ     []ical.Occurrence // expanded at last scan, sorted by start
+	readErr bool              // no calendar file could be read
+	lastSec int64
+	blk     Block
+}
+
+type fileStamp struct {
+	mod  int64
+	size int64
+}
+
+func NewCalendarProvider(mcfg config.CalendarModule, instance string) *CalendarProvider {
+	paths := make([]string, 0, len(mcfg.Paths))
+	for _, p := range mcfg.Paths {
+		paths = append(paths, config.ExpandHome(p))
+	}
+	cp := &CalendarProvider{
+		paths:         paths,
+		scanNs:        int64(time.Duration(mcfg.IntervalSec) * time.Second),
+		lookahead:     time.Duration(mcfg.LookaheadHours) * time.Hour,
+		warn:          time.Duration(mcfg.WarnMinutes) * time.Minute,
+		danger:        time.Duration(mcfg.DangerMinutes) * time.Minute,
+		urgent:        time.Duration(mcfg.UrgentMinutes) * time.Minute,
+		includeAllDay: mcfg.IncludeAllDay,
+		maxLength:     mcfg.MaxLength,
+		prefix:        mcfg.Prefix,
+		emptyText:     mcfg.EmptyText,
+		onClick:       mcfg.OnClick,
+		instance:      instance,
+	}
+	if cp.scanNs <= 0 {
+		cp.scanNs = int64(time.Minute)
+	}
+	if cp.lookahead <= 0 {
+		cp.lookahead = 24 * time.Hour
+	}
+	if cp.maxLength <= 0 {
+		cp.maxLength = 30
+	}
+	now := time.Now().UnixNano()
+	cp.scan(now)
+	cp.MaybeRefresh(now)
+	return cp
+}
+
+func init() {
+	Register(ProviderSpec{
+		Name:   "calendar",
+		Enable: func(cfg *config.Config, instance string) bool { return cfg.CalendarFor(instance).Enabled },
+		Build: func(cfg *config.Config, instance string) Provider {
+			return NewCalendarProvider(cfg.CalendarFor(instance), instance)
+		},
+	})
+}
+
+func (c *CalendarProvider) Name() string { return "calendar" }
+
+func (c *CalendarProvider) MaybeRefresh(now int64) bool {
+	if now-c.lastScanNs >= c.scanNs {
+		c.scan(now)
+	}
+	sec := now / int64(time.Second)
+	if sec == c.lastSec {
+		return false
+	}
+	c.lastSec = sec
+	blk := c.render(time.Unix(sec, 0))
+	if blk == c.blk {
+		return false
+	}
+	c.blk = blk
+	return true
+}
+
+func (c *CalendarProvider) Current() Block { return c.blk }
+
+// HandleClick runs the configured command on left click.
+func (c *CalendarProvider) HandleClick(ev clicks.Click, now int64) bool {
+	if ev.Button == clicks.ButtonLeft {
+		runCommand(c.onClick)
+	}
+	return false
+}
+
+// render picks the event to show: one that just began (urgent), else the next
+// upcoming one (colored by proximity), else one still in progress.
+func (c *CalendarProvider) render(now time.Time) Block {
+	if c.readErr {
+		blk := ErrorBlock("calendar", "cal err")
+		blk.Instance = c.instance
+		return blk
+	}
+	var current, next *ical.Occurrence
+	for i := range c.occ {
+		o := &c.occ[i]
+		if o.Event.AllDay && !c.includeAllDay {
+			continue
+		}
+		if o.Start.After(now) {
+			next = o
+			break
+		}
+		if current == nil && (now.Before(o.End) || now.Sub(o.Start) < c.urgent) {
+			current = o
+		}
+	}
+	blk := Block{Name: "calendar", Instance: c.instance, Separator: false, SeparatorBlockWidth: SeparatorWidth, Prefix: c.prefix}
+	sev := theme.SeverityNormal
+	switch {
+	case current != nil && now.Sub(current.Start) < c.urgent:
+		blk.FullText = c.text(current, "now")
+		blk.Urgent = true
+		sev = theme.SeverityDanger
+	case next != nil && next.Start.Sub(now) <= c.lookahead:
+		until := next.Start.Sub(now)
+		blk.FullText = c.text(next, "in "+formatCountdown(until))
+		if until <= c.danger {
+			sev = theme.SeverityDanger
+		} else if until <= c.warn {
+			sev = theme.SeverityWarn
+		}
+	case current != nil:
+		blk.FullText = c.text(current, "until "+current.End.In(time.Local).Format("15:04"))
+	default:
+		blk.FullText = strings.TrimSpace(c.prefix + " " + c.emptyText)
+	}
+	blk.Severity = sev
+	if color, ok := theme.ColorFor(sev); ok {
+		blk.Color = color
+	}
+	return blk
+}
+
+func (c *CalendarProvider) text(o *ical.Occurrence, when string) string {
+	summary := o.Event.Summary
+	if summary == "" {
+		summary = "(no title)"
+	}
+	if r := []rune(summary); len(r) > c.maxLength {
+		summary = string(r[:c.maxLength-1]) + "…"
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %s %s", c.prefix, summary, when))
+}
+
+// scan re-parses calendar files if any were added, removed or modified since
+// the last scan, then re-expands occurrences for the lookahead window.
+func (c *CalendarProvider) scan(now int64) {
+	c.lastScanNs = now
+	stamps := map[string]fileStamp{}
+	for _, p := range c.paths {
+		collectICS(p, stamps)
+	}
+	if !sameStamps(stamps, c.stamps) || c.stamps == nil {
+		c.stamps = stamps
+		c.events = nil
+		readAny := false
+		for path := range stamps {
+			evs, err := readICS(path)
+			if err != nil {
+				slog.Warn("read calendar", "module", "calendar", "instance", c.instance, "path", path, "err", err)
+				continue
+			}
+			readAny = true
+			c.events = append(c.events, evs...)
+		}
+		c.readErr = !readAny && len(c.paths) > 0
+	}
+	t := time.Unix(0, now)
+	c.occ = ical.Expand(c.events, t, t.Add(c.lookahead+time.Duration(c.scanNs)))
+}
+
+// collectICS records path (or every *.ics below it, if a directory) in stamps.
+func collectICS(path string, stamps map[string]fileStamp) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if !info.IsDir() {
+		stamps[path] = fileStamp{mod: info.ModTime().UnixNano(), size: info.Size()}
+		return
+	}
+	_ = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.EqualFold(filepath.Ext(p), ".ics") {
+			return nil
+		}
+		if fi, err := d.Info(); err == nil {
+			stamps[p] = fileStamp{mod: fi.ModTime().UnixNano(), size: fi.Size()}
+		}
+		return nil
+	})
+}
+
+func readICS(path string) ([]ical.Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ical.Parse(f)
+}
+
+func sameStamps(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// formatCountdown renders a positive duration rounded up to whole minutes,
+// e.g. "4m", "1h05m", "2d3h".
+func formatCountdown(d time.Duration) string {
+	mins := int((d + time.Minute - 1) / time.Minute)
+	switch {
+	case mins < 60:
+		return fmt.Sprintf("%dm", mins)
+	case mins < 24*60:
+		return fmt.Sprintf("%dh%02dm", mins/60, mins%60)
+	default:
+		return fmt.Sprintf("%dd%dh", mins/(24*60), mins%(24*60)/60)
+	}
+}
@@ -8,58 +8,55 @@ import (
 	"strconv"
 	"time"
 
+	"swaystats/clicks"
 	"swaystats/config"
+	"swaystats/metrics"
 	"swaystats/theme"
 )
 
 // CpuProvider implements aggregate CPU utilization using /proc/stat deltas.
 type CpuProvider struct {
-	intervalNs      int64
-	lastSampleNs    int64
-	prevTotal       uint64
-	prevIdle        uint64
-	havePrev        bool
-	lastPercent     float64
-	blk             Block
-	warnThreshold   float64
-	dangerThreshold float64
-	precision       int // 0 or 1
-	prefix          string
+	intervalNs   int64
+	lastSampleNs int64
+	prevTotal    uint64
+	prevIdle     uint64
+	havePrev     bool
+	lastPercent  float64
+	good         Block // last successfully sampled block (or error block if none)
+	blk          Block // good as displayed, with failure decoration
+	fail         *failures
+	threshold    *threshold
+	history      *history // nil unless a graph is configured
+	precision    int      // 0 or 1
+	prefix       string
+	instance     string
 }
 
-func NewCpuProvider(cfg *config.Config) *CpuProvider {
-	iv := cfg.Modules.CPU.IntervalSec
+func NewCpuProvider(mcfg config.CPUModule, instance string) *CpuProvider {
+	iv := mcfg.IntervalSec
 	if iv <= 0 {
 		iv = 2
 	}
 	if iv > 30 {
 		iv = 30
 	}
-	warn := cfg.Modules.CPU.WarnPercent
-	if warn <= 0 {
-		warn = 70
-	}
-	danger := cfg.Modules.CPU.DangerPercent
-	if danger <= warn {
-		danger = warn + 10
-	}
-	if danger > 100 {
-		danger = 100
-	}
-	precision := cfg.Modules.CPU.Precision
+	precision := mcfg.Precision
 	if precision < 0 || precision > 1 {
 		precision = 0
 	}
-	prefix := cfg.Modules.CPU.Prefix
+	prefix := mcfg.Prefix
 	if prefix == "" {
 		prefix = "CPU"
 	}
+	intervalNs := int64(time.Duration(iv) * time.Second)
 	cp := &CpuProvider{
-		intervalNs:      int64(time.Duration(iv) * time.Second),
-		warnThreshold:   float64(warn),
-		dangerThreshold: float64(danger),
-		precision:       precision,
-		prefix:          prefix,
+		intervalNs: intervalNs,
+		fail:       newFailures("cpu", instance, intervalNs),
+		threshold:  newThreshold(mcfg.Thresholds, "cpu", instance),
+		history:    newHistory(mcfg.History),
+		precision:  precision,
+		prefix:     prefix,
+		instance:   instance,
 	}
 	// Force initial sample so we have a baseline (will likely show 0% first time).
 	cp.sample(time.Now().UnixNano())
@@ -69,15 +66,17 @@ func NewCpuProvider(cfg *config.Config) *CpuProvider {
 func init() {
 	Register(ProviderSpec{
 		Name:   "cpu",
-		Enable: func(cfg *config.Config) bool { return cfg.Modules.CPU.Enabled },
-		Build:  func(cfg *config.Config) Provider { return NewCpuProvider(cfg) },
+		Enable: func(cfg *config.Config, instance string) bool { return cfg.CPUFor(instance).Enabled },
+		Build: func(cfg *config.Config, instance string) Provider {
+			return NewCpuProvider(cfg.CPUFor(instance), instance)
+		},
 	})
 }
 
 func (c *CpuProvider) Name() string { return "cpu" }
 
 func (c *CpuProvider) MaybeRefresh(now int64) bool {
-	if now-c.lastSampleNs < c.intervalNs {
+	if !c.fail.Due(now, c.lastSampleNs) {
 		return false
 	}
 	changed := c.sample(now)
@@ -86,15 +85,30 @@ func (c *CpuProvider) MaybeRefresh(now int64) bool {
 
 func (c *CpuProvider) Current() Block { return c.blk }
 
+func (c *CpuProvider) Warm(now int64) { c.sample(now) }
+
+func (c *CpuProvider) HandleClick(ev clicks.Click, now int64) bool {
+	return c.fail.HandleClick(ev) && c.fail.Render(c.good, &c.blk)
+}
+
+func (c *CpuProvider) Metrics() []metrics.Sample {
+	return []metrics.Sample{
+		gauge("swaystats_cpu_usage_percent", "Aggregate CPU utilization over the last sampling interval.", c.instance, c.lastPercent),
+		severitySample(c.threshold),
+	}
+}
+
 func (c *CpuProvider) sample(now int64) bool {
 	user, nice, system, idle, iowait, irq, softirq, steal, err := readProcStat()
 	if err != nil {
-		// On error, keep existing block; if we never had one, create error block.
-		if c.blk.FullText == "" {
-			c.blk = ErrorBlock("cpu", "cpu err")
-		}
+		// On error, keep the last good block; if we never had one, show an error block.
 		c.lastSampleNs = now
-		return false
+		c.fail.Fail(err, now)
+		if c.good.FullText == "" {
+			c.good = ErrorBlock("cpu", "cpu err")
+			c.good.Instance = c.instance
+		}
+		return c.fail.Render(c.good, &c.blk)
 	}
 	idleAll := idle + iowait
 	nonIdle := user + nice + system + irq + softirq + steal
@@ -108,6 +122,9 @@ func (c *CpuProvider) sample(now int64) bool {
 		} else {
 			percent = c.lastPercent // reuse
 		}
+		if c.history != nil { // the 0% baseline sample below is not history
+			c.history.Push(percent)
+		}
 	} else {
 		percent = 0
 		c.havePrev = true
@@ -115,34 +132,27 @@ func (c *CpuProvider) sample(now int64) bool {
 	c.prevTotal = total
 	c.prevIdle = idleAll
 	c.lastSampleNs = now
-
-	// Round according to precision.
-	formattedPercent := formatPercent(percent, c.precision)
-	if formattedPercent == formatPercent(c.lastPercent, c.precision) && c.blk.FullText != "" {
-		c.lastPercent = percent
-		return false // no visible change
-	}
 	c.lastPercent = percent
 
-	sev := theme.SeverityNormal
-	if percent >= c.dangerThreshold {
-		sev = theme.SeverityDanger
-	} else if percent >= c.warnThreshold {
-		sev = theme.SeverityWarn
-	}
-	color, ok := theme.ColorFor(sev)
-	full := fmt.Sprintf("%s %s", c.prefix, formattedPercent)
+	// Severity may change without the rounded text changing (sustain/hysteresis),
+	// so compare the whole block.
+	sev := c.threshold.Update(percent, now)
 	blk := Block{
 		Name:                "cpu",
-		FullText:            full,
+		Instance:            c.instance,
+		FullText:            fmt.Sprintf("%s %s", graphPrefix(c.prefix, c.history), formatPercent(percent, c.precision)),
 		Separator:           false,
 		SeparatorBlockWidth: SeparatorWidth,
+		Severity:            sev,
+		Percentage:          int(percent + 0.5),
+		Prefix:              c.prefix,
 	}
-	if ok {
+	if color, ok := theme.ColorFor(sev); ok {
 		blk.Color = color
 	}
-	c.blk = blk
-	return true
+	c.fail.Ok()
+	c.good = blk
+	return c.fail.Render(c.good, &c.blk)
 }
 
 func formatPercent(p float64, precision int) string {
@@ -0,0 +1,85 @@
+package blocks
+
+import (
+	"strings"
+	"time"
+
+	"swaystats/clicks"
+	"swaystats/config"
+	"swaystats/stats"
+	"swaystats/theme"
+)
+
+// DebugProvider shows swaystats' own footprint: rows emitted per minute and
+// the provider with the highest mean refresh time. Left click dumps the full
+// statistics to the log (as does SIGUSR2).
+type DebugProvider struct {
+	intervalNs   int64
+	lastSampleNs int64
+	prefix       string
+	instance     string
+	blk          Block
+}
+
+func NewDebugProvider(mcfg config.DebugModule, instance string) *DebugProvider {
+	dp := &DebugProvider{
+		intervalNs: int64(time.Duration(mcfg.IntervalSec) * time.Second),
+		prefix:     mcfg.Prefix,
+		instance:   instance,
+	}
+	dp.sample(time.Now().UnixNano())
+	return dp
+}
+
+func init() {
+	Register(ProviderSpec{
+		Name:   "debug",
+		Enable: func(cfg *config.Config, instance string) bool { return cfg.DebugFor(instance).Enabled },
+		Build: func(cfg *config.Config, instance string) Provider {
+			return NewDebugProvider(cfg.DebugFor(instance), instance)
+		},
+	})
+}
+
+func (d *DebugProvider) Name() string { return "debug" }
+
+func (d *DebugProvider) MaybeRefresh(now int64) bool {
+	if now-d.lastSampleNs < d.intervalNs {
+		return false
+	}
+	return d.sample(now)
+}
+
+func (d *DebugProvider) Current() Block { return d.blk }
+
+func (d *DebugProvider) HandleClick(c clicks.Click, now int64) bool {
+	if c.Button == clicks.ButtonLeft {
+		stats.Default.Snapshot(time.Unix(0, now)).Log()
+	}
+	return false
+}
+
+func (d *DebugProvider) sample(now int64) bool {
+	d.lastSampleNs = now
+	snap := stats.Default.Snapshot(time.Unix(0, now))
+	blk := Block{
+		Name:                "debug",
+		Instance:            d.instance,
+		FullText:            strings.TrimSpace(d.prefix + " " + snap.Summary()),
+		Separator:           false,
+		SeparatorBlockWidth: SeparatorWidth,
+		Prefix:              d.prefix,
+	}
+	for _, p := range snap.Providers {
+		if p.Slow > 0 {
+			blk.Severity = theme.SeverityWarn
+			blk.Color, _ = theme.ColorFor(theme.SeverityWarn)
+			break
+		}
+	}
+	if blk == d.blk {
+		return false
+	}
+	d.blk = blk
+	return true
+}
@@ -0,0 +1,168 @@
+package blocks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+
+	"swaystats/clicks"
+	"swaystats/config"
+	"swaystats/metrics"
+	"swaystats/theme"
+)
+
+// DiskProvider reports filesystem usage for the filesystem containing path.
+type DiskProvider struct {
+	intervalNs   int64
+	lastSampleNs int64
+	lastPercent  float64
+	lastUsed     uint64 // bytes
+	lastAvail    uint64 // bytes
+	good         Block  // last successfully sampled block (or error block if none)
+	blk          Block  // good as displayed, with failure decoration
+	fail         *failures
+	threshold    *threshold
+	history      *history // nil unless a graph is configured
+	precision    int
+	prefix       string
+	format       string // percent|available|used
+	path         string
+	instance     string
+}
+
+func NewDiskProvider(mcfg config.DiskModule, instance string) *DiskProvider {
+	iv := mcfg.IntervalSec
+	if iv <= 0 {
+		iv = 30
+	}
+	if iv > 600 {
+		iv = 600
+	}
+	precision := mcfg.Precision
+	if precision < 0 || precision > 1 {
+		precision = 0
+	}
+	format := strings.ToLower(mcfg.Format)
+	switch format {
+	case "percent", "available", "used":
+	default:
+		format = "percent"
+	}
+	prefix := mcfg.Prefix
+	if prefix == "" {
+		prefix = "DISK"
+	}
+	path := mcfg.Path
+	if path == "" {
+		path = "/"
+	}
+	intervalNs := int64(time.Duration(iv) * time.Second)
+	dp := &DiskProvider{
+		intervalNs: intervalNs,
+		fail:       newFailures("disk", instance, intervalNs),
+		threshold:  newThreshold(mcfg.Thresholds, "disk", instance),
+		history:    newHistory(mcfg.History),
+		precision:  precision,
+		prefix:     prefix,
+		format:     format,
+		path:       path,
+		instance:   instance,
+	}
+	dp.sample(time.Now().UnixNano())
+	return dp
+}
+
+func init() {
+	Register(ProviderSpec{
+		Name:   "disk",
+		Enable: func(cfg *config.Config, instance string) bool { return cfg.DiskFor(instance).Enabled },
+		Build: func(cfg *config.Config, instance string) Provider {
+			return NewDiskProvider(cfg.DiskFor(instance), instance)
+		},
+	})
+}
+
+func (d *DiskProvider) Name() string { return "disk" }
+
+func (d *DiskProvider) MaybeRefresh(now int64) bool {
+	if !d.fail.Due(now, d.lastSampleNs) {
+		return false
+	}
+	return d.sample(now)
+}
+
+func (d *DiskProvider) Current() Block { return d.blk }
+
+func (d *DiskProvider) HandleClick(ev clicks.Click, now int64) bool {
+	return d.fail.HandleClick(ev) && d.fail.Render(d.good, &d.blk)
+}
+
+func (d *DiskProvider) Metrics() []metrics.Sample {
+	path := metrics.Label{Name: "path", Value: d.path}
+	return []metrics.Sample{
+		gauge("swaystats_disk_used_percent", "Filesystem usage as reported by df.", d.instance, d.lastPercent, path),
+		gauge("swaystats_disk_used_bytes", "Bytes used on the filesystem.", d.instance, float64(d.lastUsed), path),
+		gauge("swaystats_disk_available_bytes", "Bytes available to unprivileged users.", d.instance, float64(d.lastAvail), path),
+		severitySample(d.threshold),
+	}
+}
+
+func (d *DiskProvider) sample(now int64) bool {
+	d.lastSampleNs = now
+	available, used, percent, err := readDiskUsage(d.path)
+	if err != nil {
+		d.fail.Fail(err, now)
+		if d.good.FullText == "" {
+			d.good = ErrorBlock("disk", "disk err")
+			d.good.Instance = d.instance
+		}
+		return d.fail.Render(d.good, &d.blk)
+	}
+	d.lastPercent = percent
+	d.lastUsed, d.lastAvail = used, available
+	if d.history != nil {
+		d.history.Push(percent)
+	}
+	prefix := graphPrefix(d.prefix, d.history)
+	var text string
+	switch d.format {
+	case "available":
+		text = fmt.Sprintf("%s %s free", prefix, humanBytes(available))
+	case "used":
+		text = fmt.Sprintf("%s %s used", prefix, humanBytes(used))
+	default: // percent
+		text = fmt.Sprintf("%s %s", prefix, formatPercent(percent, d.precision))
+	}
+	sev := d.threshold.Update(percent, now)
+	blk := Block{Name: "disk", Instance: d.instance, FullText: text, Separator: false, SeparatorBlockWidth: SeparatorWidth,
+		Severity: sev, Percentage: int(percent + 0.5), Prefix: d.prefix}
+	if color, ok := theme.ColorFor(sev); ok {
+		blk.Color = color
+	}
+	d.fail.Ok()
+	d.good = blk
+	return d.fail.Render(d.good, &d.blk)
+}
+
+// readDiskUsage returns available bytes, used bytes and percent used for the
+// filesystem holding path. Percent matches df: used / (used + available to
+// unprivileged users), so reserved blocks count as unavailable.
+func readDiskUsage(path string) (available, used uint64, percent float64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, 0, err
+	}
+	bsize := uint64(st.Bsize)
+	if st.Blocks == 0 || bsize == 0 {
+		return 0, 0, 0, errors.New("empty filesystem")
+	}
+	used = (st.Blocks - st.Bfree) * bsize
+	available = st.Bavail * bsize
+	if used+available == 0 {
+		return available, used, 0, nil
+	}
+	percent = float64(used) / float64(used+available) * 100
+	return available, used, percent, nil
+}
@@ -9,6 +9,7 @@ func ErrorBlock(name, msg string) Block {
 		FullText:            msg,
 		Separator:           false,
 		SeparatorBlockWidth: SeparatorWidth,
+		Severity:            theme.SeverityDanger,
 	}
 	if c, ok := theme.ColorFor(theme.SeverityDanger); ok {
 		b.Color = c
@@ -0,0 +1,196 @@
+package blocks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"swaystats/clicks"
+	"swaystats/config"
+	"swaystats/theme"
+)
+
+// runCommand starts cmd via `sh -c` without waiting for it; the process is
+// reaped on a separate goroutine so click handling never blocks the tick.
+// Its output goes to stderr since stdout is reserved for the protocol.
+// env entries ("KEY=value") are added to the inherited environment.
+func runCommand(cmd string, env ...string) {
+	if cmd == "" {
+		return
+	}
+	c := exec.Command("sh", "-c", cmd)
+	if len(env) > 0 {
+		c.Env = append(os.Environ(), env...)
+	}
+	c.Stdout = os.Stderr
+	c.Stderr = os.Stderr
+	if err := c.Start(); err != nil {
+		slog.Warn("command failed", "cmd", cmd, "err", err)
+		return
+	}
+	go func() {
+		if err := c.Wait(); err != nil {
+			slog.Warn("command failed", "cmd", cmd, "err", err)
+		}
+	}()
+}
+
+const (
+	execTimeout    = 10 * time.Second // longest a single run of an exec command may take
+	execUrgentCode = 33               // exit status marking the block urgent (as in i3blocks)
+	execRetryNs    = int64(5 * time.Second)
+)
+
+// ExecProvider shows the output of a command run every interval_sec, using
+// the i3blocks conventions (see config.ExecModule), so most i3blocks scripts
+// work unchanged. It is async by default.
+type ExecProvider struct {
+	command      string
+	prefix       string
+	label        string // instance, or "exec" for the base table
+	instance     string
+	env          []string // BLOCK_NAME, BLOCK_INSTANCE and the configured env
+	timeout      time.Duration
+	intervalNs   int64 // 0: run only at startup and on clicks
+	lastSampleNs int64
+	succeeded    bool // some run succeeded, so clicks go to the command
+	good         Block
+	blk          Block
+	fail         *failures
+}
+
+func NewExecProvider(mcfg config.ExecModule, instance string) *ExecProvider {
+	label := instance
+	if label == "" {
+		label = "exec"
+	}
+	intervalNs := int64(time.Duration(mcfg.IntervalSec) * time.Second)
+	ep := &ExecProvider{
+		command:    mcfg.Command,
+		prefix:     mcfg.Prefix,
+		label:      label,
+		instance:   instance,
+		env:        append([]string{"BLOCK_NAME=" + label, "BLOCK_INSTANCE=" + mcfg.BlockInstance}, mcfg.Env...),
+		timeout:    execTimeout,
+		intervalNs: intervalNs,
+		fail:       newFailures("exec", instance, max(intervalNs, execRetryNs)),
+	}
+	ep.sample(time.Now().UnixNano())
+	return ep
+}
+
+func init() {
+	Register(ProviderSpec{
+		Name: "exec",
+		Enable: func(cfg *config.Config, instance string) bool {
+			m := cfg.ExecFor(instance)
+			return m.Enabled && m.Command != ""
+		},
+		Build: func(cfg *config.Config, instance string) Provider {
+			return NewExecProvider(cfg.ExecFor(instance), instance)
+		},
+	})
+}
+
+func (e *ExecProvider) Name() string { return "exec" }
+
+func (e *ExecProvider) MaybeRefresh(now int64) bool {
+	if e.intervalNs == 0 && e.fail.count == 0 {
+		return false
+	}
+	if !e.fail.Due(now, e.lastSampleNs) {
+		return false
+	}
+	return e.sample(now)
+}
+
+func (e *ExecProvider) Current() Block { return e.blk }
+
+// HandleClick re-runs the command with the click in its environment. Until
+// the command has succeeded once, a left click toggles the error text
+// instead; afterwards clicks always reach the command, which may use them
+// to recover (reconnect and the like).
+func (e *ExecProvider) HandleClick(c clicks.Click, now int64) bool {
+	if !e.succeeded && e.fail.HandleClick(c) {
+		return e.fail.Render(e.good, &e.blk)
+	}
+	return e.sample(now,
+		fmt.Sprintf("BLOCK_BUTTON=%d", c.Button),
+		fmt.Sprintf("BLOCK_X=%d", c.X),
+		fmt.Sprintf("BLOCK_Y=%d", c.Y),
+	)
+}
+
+func (e *ExecProvider) sample(now int64, env ...string) bool {
+	e.lastSampleNs = now
+	blk, err := e.run(env)
+	if err != nil {
+		e.fail.Fail(err, now)
+		if e.good.FullText == "" {
+			e.good = ErrorBlock("exec", e.label+" err")
+			e.good.Instance = e.instance
+		}
+		return e.fail.Render(e.good, &e.blk)
+	}
+	e.fail.Ok()
+	e.succeeded = true
+	e.good = blk
+	return e.fail.Render(e.good, &e.blk)
+}
+
+// run runs the command once and parses its output.
+func (e *ExecProvider) run(env []string) (Block, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	c := exec.CommandContext(ctx, "sh", "-c", e.command)
+	// Kill the whole group on timeout: a pipeline or background child holding
+	// stdout open would otherwise keep Run waiting.
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	c.Cancel = func() error { return syscall.Kill(-c.Process.Pid, syscall.SIGKILL) }
+	c.WaitDelay = 100 * time.Millisecond
+	c.Env = append(os.Environ(), e.env...)
+	c.Env = append(c.Env, env...)
+	var stdout bytes.Buffer
+	c.Stdout = &stdout
+	c.Stderr = os.Stderr
+	err := c.Run()
+	var exitErr *exec.ExitError
+	urgent := errors.As(err, &exitErr) && exitErr.ExitCode() == execUrgentCode
+	if err != nil && !urgent {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return Block{}, err
+	}
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	line := func(i int) string {
+		if i < len(lines) {
+			return strings.TrimSpace(lines[i])
+		}
+		return ""
+	}
+	blk := Block{
+		Name:                "exec",
+		Instance:            e.instance,
+		FullText:            line(0),
+		ShortText:           line(1),
+		Color:               line(2),
+		Urgent:              urgent,
+		SeparatorBlockWidth: SeparatorWidth,
+	}
+	if e.prefix != "" {
+		blk.FullText = e.prefix + " " + blk.FullText
+		blk.Prefix = e.prefix
+	}
+	if urgent {
+		blk.Severity = theme.SeverityDanger
+	}
+	return blk, nil
+}
@@ -0,0 +1,76 @@
+package blocks
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"swaystats/clicks"
+	"swaystats/config"
+)
+
+func TestExecTimeoutKillsPipeline(t *testing.T) {
+	e := &ExecProvider{command: "sleep 30 | cat", timeout: 200 * time.Millisecond}
+	start := time.Now()
+	_, err := e.run(nil)
+	if took := time.Since(start); took > 2*time.Second {
+		t.Errorf("run took %v with a %v timeout", took, e.timeout)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want a timeout", err)
+	}
+}
+
+func TestExecEnvironment(t *testing.T) {
+	tests := []struct {
+		name string
+		mcfg config.ExecModule
+		want string
+	}{
+		{"defaults", config.ExecModule{}, "vol||"},
+		{"instance", config.ExecModule{BlockInstance: "eth0; echo x"}, "vol|eth0; echo x|"},
+		{"env", config.ExecModule{Env: []string{"IFACE=$(false) ${X}", "BLOCK_NAME=volume"}}, "volume||$(false) ${X}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mcfg.Command = `printf '%s|%s|%s\n' "$BLOCK_NAME" "$BLOCK_INSTANCE" "$IFACE"`
+			e := NewExecProvider(tt.mcfg, "vol")
+			if got := e.Current().FullText; got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecClickWhileFailing(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "up")
+	// Fails until a left click creates the state file.
+	cmd := `[ "$BLOCK_BUTTON" = 1 ] && touch ` + state + `; [ -e ` + state + ` ] && echo up`
+	left := clicks.Click{Button: clicks.ButtonLeft}
+	tests := []struct {
+		name      string
+		succeeded bool // a run succeeded before the failures
+		reached   bool // the click re-ran the command
+	}{
+		{"never succeeded: click toggles the error", false, false},
+		{"succeeded before: click reaches the command", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(state)
+			e := NewExecProvider(config.ExecModule{Command: cmd}, "vpn")
+			e.succeeded = tt.succeeded
+			if e.fail.count == 0 {
+				t.Fatal("command did not fail")
+			}
+			e.HandleClick(left, time.Now().UnixNano())
+			reached := e.Current().FullText == "up"
+			if reached != tt.reached || e.fail.showErr == tt.reached {
+				t.Errorf("reached = %v, error shown = %v; want reached = %v", reached, e.fail.showErr, tt.reached)
+			}
+		})
+	}
+}
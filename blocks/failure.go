@@ -0,0 +1,108 @@
+package blocks
+
+import (
+	"log/slog"
+	"time"
+
+	"swaystats/clicks"
+	"swaystats/theme"
+)
+
+// Shared failure policy of sampling providers.
+const (
+	staleAfter   = 3 // consecutive failures before the block is marked stale
+	maxBackoffNs = int64(5 * time.Minute)
+	errorMarker  = "!" // appended to a stale block
+	maxErrorText = 40  // runes of the error shown on click
+)
+
+// failures tracks consecutive sampling errors of one provider. After an
+// error the next attempt is delayed exponentially (interval, 2×, 4×, … capped
+// at five minutes); after staleAfter failures the last good block is shown
+// with a marker in the warn color; a left click toggles the error message.
+// The first success clears everything.
+type failures struct {
+	module, instance string
+	intervalNs       int64
+	count            int
+	lastErr          error
+	retryAt          int64
+	showErr          bool
+}
+
+func newFailures(module, instance string, intervalNs int64) *failures {
+	return &failures{module: module, instance: instance, intervalNs: intervalNs}
+}
+
+// Due reports whether a sample should be taken at now, given the last attempt.
+func (f *failures) Due(now, lastSampleNs int64) bool {
+	if f.count == 0 {
+		return now-lastSampleNs >= f.intervalNs
+	}
+	return now >= f.retryAt
+}
+
+// Fail records an error at now and schedules the next attempt.
+func (f *failures) Fail(err error, now int64) {
+	f.count++
+	f.lastErr = err
+	delay := f.intervalNs
+	for i := 1; i < f.count && delay < maxBackoffNs; i++ {
+		delay *= 2
+	}
+	delay = min(delay, max(maxBackoffNs, f.intervalNs))
+	f.retryAt = now + delay
+	if f.count == 1 || f.count == staleAfter {
+		slog.Warn("sample failed", "module", f.module, "instance", f.instance, "failures", f.count, "retry_in", time.Duration(delay), "err", err)
+	}
+}
+
+// Ok records a successful sample.
+func (f *failures) Ok() {
+	if f.count > 0 {
+		slog.Info("sample recovered", "module", f.module, "instance", f.instance, "failures", f.count)
+	}
+	f.count, f.lastErr, f.showErr = 0, nil, false
+}
+
+// Apply decorates blk (the last good block, or an error block if there was
+// none) according to the current failure state.
+func (f *failures) Apply(blk Block) Block {
+	switch {
+	case f.count == 0:
+		return blk
+	case f.showErr:
+		text := []rune(f.module + ": " + f.lastErr.Error())
+		if len(text) > maxErrorText {
+			text = append(text[:maxErrorText-1], '…')
+		}
+		blk.FullText = string(text)
+	case f.count >= staleAfter:
+		blk.FullText += errorMarker
+	default:
+		return blk
+	}
+	blk.Severity = max(blk.Severity, theme.SeverityWarn)
+	blk.Color, _ = theme.ColorFor(blk.Severity)
+	return blk
+}
+
+// Render stores the decorated good block in *out and reports whether it changed.
+func (f *failures) Render(good Block, out *Block) bool {
+	blk := f.Apply(good)
+	if blk == *out {
+		return false
+	}
+	*out = blk
+	return true
+}
+
+// HandleClick toggles the error message on left click while failing and
+// reports whether the decoration changed.
+func (f *failures) HandleClick(c clicks.Click) bool {
+	if f.count == 0 || c.Button != clicks.ButtonLeft {
+		return false
+	}
+	f.showErr = !f.showErr
+	return true
+}
@@ -0,0 +1,112 @@
+package blocks
+
+import (
+	"strings"
+
+	"swaystats/config"
+)
+
+// history is a fixed-size ring of recent samples (0..100 percent) that can be
+// drawn as a sparkline or braille graph. It is fed on every sample, whether or
+// not the visible text changed.
+type history struct {
+	style string // sparkline|braille
+	buf   []float64
+	next  int
+	count int
+}
+
+// newHistory returns nil when graphs are disabled, so callers can guard with h != nil.
+func newHistory(c config.History) *history {
+	if c.Graph == "" || c.Graph == "none" || c.GraphWidth <= 0 {
+		return nil
+	}
+	size := c.GraphWidth
+	if c.Graph == "braille" {
+		size *= 2 // two samples per braille cell
+	}
+	return &history{style: c.Graph, buf: make([]float64, size)}
+}
+
+func (h *history) Push(v float64) {
+	h.buf[h.next] = v
+	h.next = (h.next + 1) % len(h.buf)
+	if h.count < len(h.buf) {
+		h.count++
+	}
+}
+
+// at returns the i-th oldest of the last len(buf) samples; missing samples are -1.
+func (h *history) at(i int) float64 {
+	missing := len(h.buf) - h.count
+	if i < missing {
+		return -1
+	}
+	return h.buf[(h.next+i)%len(h.buf)]
+}
+
+// String renders the graph at a fixed width; missing samples draw as blanks.
+func (h *history) String() string {
+	var sb strings.Builder
+	if h.style == "braille" {
+		for i := 0; i < len(h.buf); i += 2 {
+			sb.WriteRune(brailleCell(h.at(i), h.at(i+1)))
+		}
+		return sb.String()
+	}
+	for i := range h.buf {
+		sb.WriteRune(sparkRune(h.at(i)))
+	}
+	return sb.String()
+}
+
+var sparkRunes = []rune("▁▂▃▄▅▆▇█")
+
+func sparkRune(v float64) rune {
+	if v < 0 {
+		return ' '
+	}
+	return sparkRunes[level(v, len(sparkRunes)-1)]
+}
+
+// Braille dot bits per column, bottom row first (dots 7,3,2,1 and 8,6,5,4).
+var (
+	brailleLeft  = [4]rune{0x40, 0x04, 0x02, 0x01}
+	brailleRight = [4]rune{0x80, 0x20, 0x10, 0x08}
+)
+
+// brailleCell draws two samples as bars filled from the bottom. Present samples
+// always light at least one dot so an idle value is distinguishable from a missing one.
+func brailleCell(left, right float64) rune {
+	r := rune(0x2800)
+	if left >= 0 {
+		for i := 0; i <= level(left, 3); i++ {
+			r |= brailleLeft[i]
+		}
+	}
+	if right >= 0 {
+		for i := 0; i <= level(right, 3); i++ {
+			r |= brailleRight[i]
+		}
+	}
+	return r
+}
+
+// level maps a percentage onto 0..steps, rounding to the nearest step.
+func level(v float64, steps int) int {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 100 {
+		return steps
+	}
+	return int(v/100*float64(steps) + 0.5)
+}
+
+// graphPrefix appends the rendered graph to prefix when h is non-nil.
+func graphPrefix(prefix string, h *history) string {
+	if h == nil {
+		return prefix
+	}
+	return prefix + " " + h.String()
+}
@@ -0,0 +1,243 @@
+package blocks
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"swaystats/config"
+)
+
+// Markup renders the text of modules with markup = "pango" from their
+// template. A template is Pango markup with placeholders:
+//
+//	{prefix}  the module's label ("CPU"); a space after an empty prefix is dropped
+//	{value}   the rest of the text ("42%")
+//	{text}    the whole text
+//
+// Each placeholder takes optional helpers, e.g. {value:bold,color}:
+//
+//	bold, italic, small  weight, style and size
+//	dim                  half transparent
+//	icon                 in the module's icon_font
+//	color                in the block color, which then no longer colors the whole block
+//
+// Placeholders are always escaped, so provider text (process names, script
+// output, error messages) cannot break the markup. A template that is not
+// well-formed is logged once and its blocks are shown as escaped plain text.
+type Markup struct {
+	templates map[string]*template // parsed templates by source; nil if invalid
+}
+
+func NewMarkup() *Markup {
+	return &Markup{templates: map[string]*template{}}
+}
+
+// Apply renders the blocks of row (one block per provider) in place.
+func (m *Markup) Apply(cfg *config.Config, providers []Provider, row []Block) {
+	for i := range row {
+		common := cfg.CommonFor(config.ModuleRef{Kind: providers[i].Name(), Instance: row[i].Instance})
+		if common.Markup != "pango" {
+			continue
+		}
+		m.template(common.Template).render(&row[i], common.IconFont)
+	}
+}
+
+// template returns the parsed template for src, falling back to {text}.
+func (m *Markup) template(src string) *template {
+	if src == "" {
+		src = "{text}"
+	}
+	t, ok := m.templates[src]
+	if !ok {
+		var err error
+		if t, err = parseTemplate(src); err != nil {
+			slog.Warn("markup template ignored", "template", src, "err", err)
+		}
+		m.templates[src] = t
+	}
+	if t == nil {
+		return plainTemplate
+	}
+	return t
+}
+
+// template is a parsed markup template: literal markup and placeholders.
+type template struct {
+	parts []part
+	color bool // some placeholder uses the color helper
+}
+
+type part struct {
+	markup  string // literal; used when field is empty
+	field   string // prefix, value or text
+	helpers []string
+}
+
+var (
+	placeholder   = regexp.MustCompile(`\{([a-z]+)(?::([a-z,]*))?\}`)
+	plainTemplate = &template{parts: []part{{field: "text"}}}
+)
+
+func parseTemplate(src string) (*template, error) {
+	t := &template{}
+	last := 0
+	for _, loc := range placeholder.FindAllStringSubmatchIndex(src, -1) {
+		p := part{field: src[loc[2]:loc[3]]}
+		switch p.field {
+		case "prefix", "value", "text":
+		default:
+			return nil, fmt.Errorf("unknown placeholder {%s}", p.field)
+		}
+		if loc[4] >= 0 {
+			for h := range strings.SplitSeq(src[loc[4]:loc[5]], ",") {
+				switch h {
+				case "bold", "italic", "small", "dim", "icon":
+				case "color":
+					t.color = true
+				default:
+					return nil, fmt.Errorf("unknown helper %q in {%s}", h, src[loc[2]:loc[5]])
+				}
+				p.helpers = append(p.helpers, h)
+			}
+		}
+		if loc[0] > last {
+			t.parts = append(t.parts, part{markup: src[last:loc[0]]})
+		}
+		t.parts = append(t.parts, p)
+		last = loc[1]
+	}
+	if last < len(src) {
+		t.parts = append(t.parts, part{markup: src[last:]})
+	}
+	// Pango rejects malformed markup with an error in place of the text.
+	if _, err := stripMarkup(t.execute("p", "v", "t", "#000000", "f")); err != nil {
+		return nil, fmt.Errorf("not well-formed: %w", err)
+	}
+	return t, nil
+}
+
+// render replaces blk's text with the template output. Text that is already
+// markup (scripts and plugins may emit it) is used unescaped if it is
+// well-formed, and escaped like plain text otherwise.
+func (t *template) render(blk *Block, iconFont string) {
+	full, short := asMarkup(*blk, blk.FullText), asMarkup(*blk, blk.ShortText)
+	prefix, value := "", full
+	if p := html.EscapeString(blk.Prefix); p != "" && strings.HasPrefix(full, p) {
+		prefix, value = p, strings.TrimPrefix(full[len(p):], " ")
+	}
+	blk.FullText = t.execute(prefix, value, full, blk.Color, iconFont)
+	blk.ShortText = short
+	blk.Markup = "pango"
+	if t.color {
+		blk.Color = ""
+	}
+}
+
+// execute fills in the placeholders with already escaped text.
+func (t *template) execute(prefix, value, text, color, iconFont string) string {
+	var sb strings.Builder
+	skipSpace := false
+	for _, p := range t.parts {
+		if p.field == "" {
+			s := p.markup
+			if skipSpace {
+				s = strings.TrimLeft(s, " ")
+			}
+			sb.WriteString(s)
+			skipSpace = false
+			continue
+		}
+		s := text
+		switch p.field {
+		case "prefix":
+			s = prefix
+		case "value":
+			s = value
+		}
+		skipSpace = p.field == "prefix" && s == ""
+		if s == "" {
+			continue
+		}
+		attrs := spanAttrs(p.helpers, color, iconFont)
+		if attrs == "" {
+			sb.WriteString(s)
+			continue
+		}
+		sb.WriteString("<span" + attrs + ">" + s + "</span>")
+	}
+	return sb.String()
+}
+
+// spanAttrs returns the Pango span attributes of helpers, with a leading space.
+func spanAttrs(helpers []string, color, iconFont string) string {
+	var sb strings.Builder
+	attr := func(name, val string) {
+		if val != "" {
+			sb.WriteString(" " + name + `="` + html.EscapeString(val) + `"`)
+		}
+	}
+	for _, h := range helpers {
+		switch h {
+		case "bold":
+			attr("weight", "bold")
+		case "italic":
+			attr("style", "italic")
+		case "small":
+			attr("size", "small")
+		case "dim":
+			attr("alpha", "50%")
+		case "icon":
+			attr("font_family", iconFont)
+		case "color":
+			attr("foreground", color)
+		}
+	}
+	return sb.String()
+}
+
+// Text returns the block's text with any Pango markup removed, for outputs
+// and rules that deal in plain text.
+func (b Block) Text() string {
+	if b.Markup != "pango" {
+		return b.FullText
+	}
+	if s, err := stripMarkup(b.FullText); err == nil {
+		return s
+	}
+	return b.FullText
+}
+
+// asMarkup returns s, a text of blk, as markup.
+func asMarkup(blk Block, s string) string {
+	if blk.Markup == "pango" {
+		if _, err := stripMarkup(s); err == nil {
+			return s
+		}
+	}
+	return html.EscapeString(s)
+}
+
+// stripMarkup returns the character data of markup, with entities resolved.
+func stripMarkup(markup string) (string, error) {
+	d := xml.NewDecoder(strings.NewReader("<markup>" + markup + "</markup>"))
+	var sb strings.Builder
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return sb.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if cd, ok := tok.(xml.CharData); ok {
+			sb.Write(cd)
+		}
+	}
+}
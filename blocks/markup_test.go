@@ -0,0 +1,57 @@
+package blocks
+
+import "testing"
+
+func TestTemplateRender(t *testing.T) {
+	tests := []struct {
+		name, template string
+		blk            Block
+		full, short    string
+	}{
+		{"plain text is escaped", "{text}",
+			Block{FullText: "a < b & c", ShortText: "<"},
+			"a &lt; b &amp; c", "&lt;"},
+		{"plugin markup is kept", "<b>{text}</b>",
+			Block{FullText: "<i>up</i> &amp; running", ShortText: "<i>up</i>", Markup: "pango"},
+			"<b><i>up</i> &amp; running</b>", "<i>up</i>"},
+		{"malformed plugin markup is escaped", "<b>{text}</b>",
+			Block{FullText: "<i>up & <b>down</i>", ShortText: "<span", Markup: "pango"},
+			"<b>&lt;i&gt;up &amp; &lt;b&gt;down&lt;/i&gt;</b>", "&lt;span"},
+		{"prefix and value", "{prefix:dim} {value:bold}",
+			Block{FullText: "CPU 42%", Prefix: "CPU"},
+			`<span alpha="50%">CPU</span> <span weight="bold">42%</span>`, ""},
+		{"escaped prefix", "{prefix:dim} {value}",
+			Block{FullText: "R&D 3", Prefix: "R&D"},
+			`<span alpha="50%">R&amp;D</span> 3`, ""},
+		{"prefix of markup text", "{prefix:dim} {value}",
+			Block{FullText: "R&amp;D <b>3</b>", Prefix: "R&D", Markup: "pango"},
+			`<span alpha="50%">R&amp;D</span> <b>3</b>`, ""},
+		{"empty prefix drops its space", "{prefix} {value}",
+			Block{FullText: "42%"},
+			"42%", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := parseTemplate(tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			blk := tt.blk
+			tmpl.render(&blk, "")
+			if blk.FullText != tt.full || blk.ShortText != tt.short || blk.Markup != "pango" {
+				t.Errorf("got %q / %q (%s), want %q / %q", blk.FullText, blk.ShortText, blk.Markup, tt.full, tt.short)
+			}
+			if _, err := stripMarkup(blk.FullText); err != nil {
+				t.Errorf("output is not well-formed: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseTemplateErrors(t *testing.T) {
+	for _, src := range []string{"{nope}", "{text:blink}", "<b>{text}", "<b>{text}</i>"} {
+		if _, err := parseTemplate(src); err == nil {
+			t.Errorf("parseTemplate(%q) succeeded", src)
+		}
+	}
+}
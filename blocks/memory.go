@@ -6,26 +6,32 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"swaystats/clicks"
 	"swaystats/config"
+	"swaystats/metrics"
 	"swaystats/theme"
 	"time"
 )
 
 // MemoryProvider provides memory utilization / availability stats.
 type MemoryProvider struct {
-	intervalNs      int64
-	lastSampleNs    int64
-	lastPercent     float64
-	blk             Block
-	warnThreshold   float64
-	dangerThreshold float64
-	precision       int
-	prefix          string
-	format          string // percent|available|used
+	intervalNs   int64
+	lastSampleNs int64
+	lastPercent  float64
+	lastTotal    uint64 // bytes
+	lastAvail    uint64 // bytes
+	good         Block  // last successfully sampled block (or error block if none)
+	blk          Block  // good as displayed, with failure decoration
+	fail         *failures
+	threshold    *threshold
+	history      *history // nil unless a graph is configured
+	precision    int
+	prefix       string
+	format       string // percent|available|used
+	instance     string
 }
 
-func NewMemoryProvider(cfg *config.Config) *MemoryProvider {
-	mcfg := cfg.Modules.Mem
+func NewMemoryProvider(mcfg config.MemoryModule, instance string) *MemoryProvider {
 	iv := mcfg.IntervalSec
 	if iv <= 0 {
 		iv = 5
@@ -33,17 +39,6 @@ func NewMemoryProvider(cfg *config.Config) *MemoryProvider {
 	if iv > 60 {
 		iv = 60
 	}
-	warn := mcfg.WarnPercent
-	if warn <= 0 {
-		warn = 70
-	}
-	danger := mcfg.DangerPercent
-	if danger <= warn {
-		danger = warn + 10
-	}
-	if danger > 100 {
-		danger = 100
-	}
 	precision := mcfg.Precision
 	if precision < 0 || precision > 1 {
 		precision = 0
@@ -58,13 +53,16 @@ func NewMemoryProvider(cfg *config.Config) *MemoryProvider {
 	if prefix == "" {
 		prefix = "MEM"
 	}
+	intervalNs := int64(time.Duration(iv) * time.Second)
 	mp := &MemoryProvider{
-		intervalNs:      int64(time.Duration(iv) * time.Second),
-		warnThreshold:   float64(warn),
-		dangerThreshold: float64(danger),
-		precision:       precision,
-		prefix:          prefix,
-		format:          format,
+		intervalNs: intervalNs,
+		fail:       newFailures("mem", instance, intervalNs),
+		threshold:  newThreshold(mcfg.Thresholds, "mem", instance),
+		history:    newHistory(mcfg.History),
+		precision:  precision,
+		prefix:     prefix,
+		format:     format,
+		instance:   instance,
 	}
 	mp.sample(time.Now().UnixNano())
 	return mp
@@ -73,15 +71,17 @@ func NewMemoryProvider(cfg *config.Config) *MemoryProvider {
 func init() {
 	Register(ProviderSpec{
 		Name:   "mem",
-		Enable: func(cfg *config.Config) bool { return cfg.Modules.Mem.Enabled },
-		Build:  func(cfg *config.Config) Provider { return NewMemoryProvider(cfg) },
+		Enable: func(cfg *config.Config, instance string) bool { return cfg.MemFor(instance).Enabled },
+		Build: func(cfg *config.Config, instance string) Provider {
+			return NewMemoryProvider(cfg.MemFor(instance), instance)
+		},
 	})
 }
 
 func (m *MemoryProvider) Name() string { return "mem" }
 
 func (m *MemoryProvider) MaybeRefresh(now int64) bool {
-	if now-m.lastSampleNs < m.intervalNs {
+	if !m.fail.Due(now, m.lastSampleNs) {
 		return false
 	}
 	return m.sample(now)
@@ -89,48 +89,57 @@ func (m *MemoryProvider) MaybeRefresh(now int64) bool {
 
 func (m *MemoryProvider) Current() Block { return m.blk }
 
+func (m *MemoryProvider) HandleClick(ev clicks.Click, now int64) bool {
+	return m.fail.HandleClick(ev) && m.fail.Render(m.good, &m.blk)
+}
+
+func (m *MemoryProvider) Metrics() []metrics.Sample {
+	return []metrics.Sample{
+		gauge("swaystats_memory_used_percent", "Memory in use as a percentage of total.", m.instance, m.lastPercent),
+		gauge("swaystats_memory_total_bytes", "Total memory.", m.instance, float64(m.lastTotal)),
+		gauge("swaystats_memory_available_bytes", "Memory available for new allocations.", m.instance, float64(m.lastAvail)),
+		severitySample(m.threshold),
+	}
+}
+
 func (m *MemoryProvider) sample(now int64) bool {
 	total, available, used, percent, err := readMemInfo()
 	if err != nil {
-		if m.blk.FullText == "" {
-			m.blk = ErrorBlock("mem", "mem err")
+		m.fail.Fail(err, now)
+		if m.good.FullText == "" {
+			m.good = ErrorBlock("mem", "mem err")
+			m.good.Instance = m.instance
 		}
 		m.lastSampleNs = now
-		return false
-	}
-	// Round percent for change detection based on precision.
-	formattedPercent := formatPercent(percent, m.precision)
-	text := m.buildText(total, available, used, formattedPercent)
-	if text == m.blk.FullText { // no visible change
-		m.lastSampleNs = now
-		m.lastPercent = percent
-		return false
+		return m.fail.Render(m.good, &m.blk)
 	}
 	m.lastSampleNs = now
 	m.lastPercent = percent
-	sev := theme.SeverityNormal
-	if percent >= m.dangerThreshold {
-		sev = theme.SeverityDanger
-	} else if percent >= m.warnThreshold {
-		sev = theme.SeverityWarn
-	}
-	color, ok := theme.ColorFor(sev)
-	blk := Block{Name: "mem", FullText: text, Separator: false, SeparatorBlockWidth: SeparatorWidth}
-	if ok {
+	m.lastTotal, m.lastAvail = total, available
+	if m.history != nil {
+		m.history.Push(percent)
+	}
+	text := m.buildText(total, available, used, formatPercent(percent, m.precision))
+	sev := m.threshold.Update(percent, now)
+	blk := Block{Name: "mem", Instance: m.instance, FullText: text, Separator: false, SeparatorBlockWidth: SeparatorWidth,
+		Severity: sev, Percentage: int(percent + 0.5), Prefix: m.prefix}
+	if color, ok := theme.ColorFor(sev); ok {
 		blk.Color = color
 	}
-	m.blk = blk
-	return true
+	m.fail.Ok()
+	m.good = blk
+	return m.fail.Render(m.good, &m.blk)
 }
 
 func (m *MemoryProvider) buildText(total, available, used uint64, percentStr string) string {
+	prefix := graphPrefix(m.prefix, m.history)
 	switch m.format {
 	case "available":
-		return fmt.Sprintf("%s %s free", m.prefix, humanBytes(available))
+		return fmt.Sprintf("%s %s free", prefix, humanBytes(available))
 	case "used":
-		return fmt.Sprintf("%s %s used", m.prefix, humanBytes(used))
+		return fmt.Sprintf("%s %s used", prefix, humanBytes(used))
 	default: // percent
-		return fmt.Sprintf("%s %s", m.prefix, percentStr)
+		return fmt.Sprintf("%s %s", prefix, percentStr)
 	}
 }
 
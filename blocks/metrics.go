@@ -0,0 +1,38 @@
+package blocks
+
+import "swaystats/metrics"
+
+// MetricsProvider is implemented by providers that export typed samples
+// alongside their Block. Metrics runs on the render goroutine.
+type MetricsProvider interface {
+	Metrics() []metrics.Sample
+}
+
+// CollectMetrics gathers the samples of every provider that exports any.
+func CollectMetrics(providers []Provider) []metrics.Sample {
+	var out []metrics.Sample
+	for _, p := range providers {
+		if mp, ok := p.(MetricsProvider); ok {
+			out = append(out, mp.Metrics()...)
+		}
+	}
+	return out
+}
+
+// gauge builds a gauge sample labelled with the block's instance. The label is
+// block_instance, since Prometheus sets instance to the scrape target.
+func gauge(name, help, instance string, value float64, labels ...metrics.Label) metrics.Sample {
+	return metrics.Sample{
+		Name:   name,
+		Help:   help,
+		Type:   metrics.Gauge,
+		Labels: append([]metrics.Label{{Name: "block_instance", Value: instance}}, labels...),
+		Value:  value,
+	}
+}
+
+// severitySample exports a threshold's current level (0 normal, 1 warn, 2 danger).
+func severitySample(t *threshold) metrics.Sample {
+	return gauge("swaystats_severity", "Block severity: 0 normal, 1 warn, 2 danger.", t.instance,
+		float64(t.level), metrics.Label{Name: "module", Value: t.module})
+}
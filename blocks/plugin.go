@@ -0,0 +1,111 @@
+package blocks
+
+import (
+	"log/slog"
+	"time"
+
+	"swaystats/clicks"
+	"swaystats/config"
+	"swaystats/plugin"
+	"swaystats/theme"
+)
+
+// pluginWarmTimeout bounds how long one-shot callers wait for a first update.
+const pluginWarmTimeout = time.Second
+
+// PluginProvider shows blocks pushed by an external plugin process. The
+// process is started with the provider and stopped by Close (config reload).
+type PluginProvider struct {
+	instance string
+	sup      *plugin.Supervisor
+	seen     uint64
+	running  bool
+	blk      Block
+}
+
+func NewPluginProvider(mcfg config.PluginModule, instance string) *PluginProvider {
+	pp := &PluginProvider{
+		instance: instance,
+		sup:      plugin.Start("plugin", instance, mcfg.Command, mcfg.Table, time.Duration(mcfg.MaxBackoffSec)*time.Second),
+		running:  true,
+	}
+	pp.blk = pp.render(plugin.Update{}, 0, true)
+	return pp
+}
+
+func init() {
+	Register(ProviderSpec{
+		Name: "plugin",
+		Enable: func(cfg *config.Config, instance string) bool {
+			m := cfg.PluginFor(instance)
+			if m.Enabled && m.Command == "" && instance != "" {
+				slog.Warn("plugin without command ignored", "module", "plugin", "instance", instance)
+			}
+			return m.Enabled && m.Command != ""
+		},
+		Build: func(cfg *config.Config, instance string) Provider {
+			return NewPluginProvider(cfg.PluginFor(instance), instance)
+		},
+	})
+}
+
+func (p *PluginProvider) Name() string { return "plugin" }
+
+func (p *PluginProvider) MaybeRefresh(now int64) bool {
+	u, version, running := p.sup.Latest()
+	if version == p.seen && running == p.running {
+		return false
+	}
+	p.seen, p.running = version, running
+	blk := p.render(u, version, running)
+	if blk == p.blk {
+		return false
+	}
+	p.blk = blk
+	return true
+}
+
+func (p *PluginProvider) Current() Block { return p.blk }
+
+func (p *PluginProvider) HandleClick(c clicks.Click, now int64) bool {
+	p.sup.Click(c)
+	return false
+}
+
+// Warm waits briefly for the plugin's first update.
+func (p *PluginProvider) Warm(now int64) {
+	p.sup.WaitUpdate(pluginWarmTimeout)
+	p.MaybeRefresh(now)
+}
+
+func (p *PluginProvider) Close() { p.sup.Stop() }
+
+// render builds the block; name and instance are always swaystats' own so
+// clicks route back to this plugin. A plugin that is down keeps its last
+// block with the error marker.
+func (p *PluginProvider) render(u plugin.Update, version uint64, running bool) Block {
+	blk := Block{
+		Name:                "plugin",
+		Instance:            p.instance,
+		FullText:            u.FullText,
+		ShortText:           u.ShortText,
+		Color:               u.Color,
+		Background:          u.Background,
+		Urgent:              u.Urgent,
+		Markup:              u.Markup,
+		Separator:           false,
+		SeparatorBlockWidth: SeparatorWidth,
+	}
+	switch {
+	case version == 0 && running:
+		blk.FullText = p.instance + staleMarker
+	case version == 0:
+		blk = ErrorBlock("plugin", p.instance+" err")
+		blk.Instance = p.instance
+	case !running:
+		blk.FullText += errorMarker
+		blk.Severity = theme.SeverityWarn
+		blk.Color, _ = theme.ColorFor(theme.SeverityWarn)
+	}
+	return blk
+}
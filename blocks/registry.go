@@ -3,10 +3,11 @@ package blocks
 import "swaystats/config"
 
 // ProviderSpec describes how to enable and build a provider.
+// Enable and Build receive the instance name ("" for the kind's base table).
 type ProviderSpec struct {
 	Name   string
-	Enable func(*config.Config) bool
-	Build  func(*config.Config) Provider
+	Enable func(cfg *config.Config, instance string) bool
+	Build  func(cfg *config.Config, instance string) Provider
 }
 
 var (
@@ -24,32 +25,35 @@ func Register(spec ProviderSpec) {
 }
 
 // BuildProviders returns provider instances in the order:
-// 1. Order of module tables as specified in config file.
+// 1. Order of module tables (and their named instances) as specified in config file.
 // 2. Remaining registered providers (those not present in config order) in registration order.
 func BuildProviders(cfg *config.Config) []Provider {
 	order := cfg.ModuleOrder()
-	seen := map[string]struct{}{}
 	providers := []Provider{}
-	appendIf := func(name string) {
-		spec, ok := reg[name]
+	appendIf := func(ref config.ModuleRef) {
+		spec, ok := reg[ref.Kind]
 		if !ok {
 			return // unknown name in config
 		}
-		if spec.Enable != nil && !spec.Enable(cfg) {
+		if spec.Enable != nil && !spec.Enable(cfg, ref.Instance) {
 			return
 		}
-		providers = append(providers, spec.Build(cfg))
-		seen[name] = struct{}{}
+		if common := cfg.CommonFor(ref); common.Async {
+			build := func() Provider { return spec.Build(cfg, ref.Instance) }
+			providers = append(providers, NewAsyncProvider(ref.Kind, ref.Instance, common.Timeout(), build))
+			return
+		}
+		providers = append(providers, spec.Build(cfg, ref.Instance))
 	}
 	if len(order) > 0 { // explicit config file: only build those listed and enabled
-		for _, n := range order {
-			appendIf(n)
+		for _, ref := range order {
+			appendIf(ref)
 		}
 		return providers
 	}
 	// No explicit file order (defaults case): use registration order
 	for _, n := range regOrder {
-		appendIf(n)
+		appendIf(config.ModuleRef{Kind: n})
 	}
 	return providers
 }
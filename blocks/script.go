@@ -0,0 +1,364 @@
+package blocks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"swaystats/clicks"
+	"swaystats/config"
+
+	"go.starlark.net/lib/json"
+	starlarktime "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// Limits of one script call (render, click or loading the file).
+const (
+	scriptMaxSteps   = 10_000_000
+	scriptMaxRuntime = 2 * time.Second
+	scriptRunTimeout = time.Second // default timeout_ms of run()
+
+	deadlineKey = "deadline" // thread-local time.Time at which the call is cancelled
+)
+
+// ScriptProvider renders a block with a Starlark script. The script defines
+// render() returning a string, a dict of block fields or None, and may define
+// click(event). It is re-read whenever the file changes. Module globals are
+// frozen by Starlark, so the predeclared `state` dict carries mutable state
+// between calls (and across reloads of the file).
+type ScriptProvider struct {
+	path         string
+	label        string // instance, or "script" for the base table
+	instance     string
+	intervalNs   int64
+	lastSampleNs int64
+	modTime      time.Time
+	predeclared  starlark.StringDict
+	globals      starlark.StringDict // nil until the script loaded
+	good         Block
+	blk          Block
+	fail         *failures
+}
+
+func NewScriptProvider(mcfg config.ScriptModule, instance string) *ScriptProvider {
+	label := instance
+	if label == "" {
+		label = "script"
+	}
+	intervalNs := int64(time.Duration(mcfg.IntervalSec) * time.Second)
+	sp := &ScriptProvider{
+		path:       config.ExpandHome(mcfg.Script),
+		label:      label,
+		instance:   instance,
+		intervalNs: intervalNs,
+		fail:       newFailures("script", instance, intervalNs),
+	}
+	sp.predeclared = starlark.StringDict{
+		"config":         toStarlark(mcfg.Table),
+		"instance":       starlark.String(label),
+		"read_file":      starlark.NewBuiltin("read_file", scriptReadFile),
+		"run":            starlark.NewBuiltin("run", scriptRun),
+		"human_bytes":    starlark.NewBuiltin("human_bytes", scriptHumanBytes),
+		"format_percent": starlark.NewBuiltin("format_percent", scriptFormatPercent),
+		"json":           json.Module,
+		"time":           starlarktime.Module,
+	}
+	sp.predeclared.Freeze()
+	sp.predeclared["state"] = starlark.NewDict(0)
+	sp.sample(time.Now().UnixNano())
+	return sp
+}
+
+func init() {
+	Register(ProviderSpec{
+		Name: "script",
+		Enable: func(cfg *config.Config, instance string) bool {
+			m := cfg.ScriptFor(instance)
+			return m.Enabled && m.Script != ""
+		},
+		Build: func(cfg *config.Config, instance string) Provider {
+			return NewScriptProvider(cfg.ScriptFor(instance), instance)
+		},
+	})
+}
+
+func (s *ScriptProvider) Name() string { return "script" }
+
+func (s *ScriptProvider) MaybeRefresh(now int64) bool {
+	if !s.fail.Due(now, s.lastSampleNs) && !s.changedOnDisk() {
+		return false
+	}
+	return s.sample(now)
+}
+
+func (s *ScriptProvider) Current() Block { return s.blk }
+
+// HandleClick passes the click event to the script's click() and re-renders.
+// Without a click() (or while failing) a left click toggles the error text.
+func (s *ScriptProvider) HandleClick(c clicks.Click, now int64) bool {
+	fn, ok := s.globals["click"].(starlark.Callable)
+	if !ok || s.fail.count > 0 {
+		return s.fail.HandleClick(c) && s.fail.Render(s.good, &s.blk)
+	}
+	event := starlark.NewDict(4)
+	event.SetKey(starlark.String("button"), starlark.MakeInt(c.Button))
+	event.SetKey(starlark.String("x"), starlark.MakeInt(c.X))
+	event.SetKey(starlark.String("y"), starlark.MakeInt(c.Y))
+	mods := make([]starlark.Value, len(c.Modifiers))
+	for i, m := range c.Modifiers {
+		mods[i] = starlark.String(m)
+	}
+	event.SetKey(starlark.String("modifiers"), starlark.NewList(mods))
+	if _, err := s.call(fn, starlark.Tuple{event}); err != nil {
+		s.fail.Fail(err, now)
+		s.lastSampleNs = now
+		return s.fail.Render(s.good, &s.blk)
+	}
+	return s.sample(now)
+}
+
+// changedOnDisk reports whether the script file was modified since it was loaded.
+func (s *ScriptProvider) changedOnDisk() bool {
+	fi, err := os.Stat(s.path)
+	return err == nil && !fi.ModTime().Equal(s.modTime)
+}
+
+func (s *ScriptProvider) sample(now int64) bool {
+	s.lastSampleNs = now
+	blk, err := s.render()
+	if err != nil {
+		s.fail.Fail(err, now)
+		if s.good.FullText == "" {
+			s.good = ErrorBlock("script", s.label+" err")
+			s.good.Instance = s.instance
+		}
+		return s.fail.Render(s.good, &s.blk)
+	}
+	s.fail.Ok()
+	s.good = blk
+	return s.fail.Render(s.good, &s.blk)
+}
+
+// render (re)loads the script if needed and calls its render().
+func (s *ScriptProvider) render() (Block, error) {
+	if s.globals == nil || s.changedOnDisk() {
+		if err := s.load(); err != nil {
+			return Block{}, err
+		}
+	}
+	fn, ok := s.globals["render"].(starlark.Callable)
+	if !ok {
+		return Block{}, errors.New("script defines no render()")
+	}
+	v, err := s.call(fn, nil)
+	if err != nil {
+		return Block{}, err
+	}
+	blk := Block{Name: "script", Instance: s.instance, Separator: false, SeparatorBlockWidth: SeparatorWidth}
+	switch v := v.(type) {
+	case starlark.NoneType:
+	case starlark.String:
+		blk.FullText = string(v)
+	case *starlark.Dict:
+		for _, item := range v.Items() {
+			key, _ := starlark.AsString(item[0])
+			if err := setBlockField(&blk, key, item[1]); err != nil {
+				return Block{}, fmt.Errorf("render(): %w", err)
+			}
+		}
+	default:
+		return Block{}, fmt.Errorf("render() returned %s, want string, dict or None", v.Type())
+	}
+	return blk, nil
+}
+
+// load executes the script file, keeping its globals.
+func (s *ScriptProvider) load() error {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	src, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	s.globals, s.modTime = nil, fi.ModTime()
+	thread := s.thread()
+	defer time.AfterFunc(scriptMaxRuntime, func() { thread.Cancel("timeout") }).Stop()
+	globals, err := starlark.ExecFileOptions(&syntax.FileOptions{}, thread, s.path, src, s.predeclared)
+	if err != nil {
+		return err
+	}
+	s.globals = globals
+	return nil
+}
+
+func (s *ScriptProvider) call(fn starlark.Callable, args starlark.Tuple) (starlark.Value, error) {
+	thread := s.thread()
+	defer time.AfterFunc(scriptMaxRuntime, func() { thread.Cancel("timeout") }).Stop()
+	return starlark.Call(thread, fn, args, nil)
+}
+
+func (s *ScriptProvider) thread() *starlark.Thread {
+	thread := &starlark.Thread{
+		Name: "script:" + s.instance,
+		Print: func(_ *starlark.Thread, msg string) {
+			slog.Info("script print", "module", "script", "instance", s.instance, "msg", msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(scriptMaxSteps)
+	thread.SetLocal(deadlineKey, time.Now().Add(scriptMaxRuntime))
+	return thread
+}
+
+// setBlockField copies one entry of a dict returned by render() into blk.
+func setBlockField(blk *Block, key string, v starlark.Value) error {
+	switch key {
+	case "full_text", "short_text", "color", "background", "markup":
+		s, ok := starlark.AsString(v)
+		if !ok {
+			return fmt.Errorf("%s: got %s, want string", key, v.Type())
+		}
+		switch key {
+		case "full_text":
+			blk.FullText = s
+		case "short_text":
+			blk.ShortText = s
+		case "color":
+			blk.Color = s
+		case "background":
+			blk.Background = s
+		case "markup":
+			blk.Markup = s
+		}
+	case "urgent":
+		blk.Urgent = bool(v.Truth())
+	default:
+		return fmt.Errorf("unknown block field %q", key)
+	}
+	return nil
+}
+
+// read_file(path) returns the contents of a file (~ is expanded).
+func scriptReadFile(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var path string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "path", &path); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(config.ExpandHome(path))
+	if err != nil {
+		return nil, err
+	}
+	return starlark.String(data), nil
+}
+
+// run(cmd, timeout_ms=1000, check=True) runs cmd via `sh -c` and returns its
+// stdout without the trailing newline. A failing command is an error, or
+// None with check=False. The command never outlives the script's own
+// runtime limit, whatever timeout_ms says.
+func scriptRun(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var cmd string
+	timeoutMs := int(scriptRunTimeout / time.Millisecond)
+	check := true
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "cmd", &cmd, "timeout_ms?", &timeoutMs, "check?", &check); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(time.Duration(timeoutMs) * time.Millisecond)
+	if limit, ok := thread.Local(deadlineKey).(time.Time); ok && limit.Before(deadline) {
+		deadline = limit
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	c := exec.CommandContext(ctx, "sh", "-c", cmd)
+	// Kill the whole group on timeout: a child of the shell holding stdout
+	// open would otherwise keep Run waiting.
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	c.Cancel = func() error { return syscall.Kill(-c.Process.Pid, syscall.SIGKILL) }
+	c.WaitDelay = 100 * time.Millisecond
+	var stdout bytes.Buffer
+	c.Stdout = &stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		if !check {
+			return starlark.None, nil
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("run %q: %w", cmd, err)
+	}
+	return starlark.String(strings.TrimSuffix(stdout.String(), "\n")), nil
+}
+
+// human_bytes(n) formats a byte count like the mem and disk blocks.
+func scriptHumanBytes(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var n int64
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "n", &n); err != nil {
+		return nil, err
+	}
+	return starlark.String(humanBytes(uint64(max(n, 0)))), nil
+}
+
+// format_percent(p, precision=0) formats a percentage like the cpu block.
+func scriptFormatPercent(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var p starlark.Value
+	precision := 0
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "p", &p, "precision?", &precision); err != nil {
+		return nil, err
+	}
+	f, ok := starlark.AsFloat(p)
+	if !ok {
+		return nil, fmt.Errorf("%s: got %s, want number", b.Name(), p.Type())
+	}
+	return starlark.String(formatPercent(f, precision)), nil
+}
+
+// toStarlark converts a decoded TOML value into a frozen Starlark value.
+func toStarlark(v any) starlark.Value {
+	var out starlark.Value
+	switch v := v.(type) {
+	case nil:
+		out = starlark.None
+	case string:
+		out = starlark.String(v)
+	case bool:
+		out = starlark.Bool(v)
+	case int64:
+		out = starlark.MakeInt64(v)
+	case float64:
+		out = starlark.Float(v)
+	case time.Time:
+		out = starlarktime.Time(v)
+	case []any:
+		elems := make([]starlark.Value, len(v))
+		for i, e := range v {
+			elems[i] = toStarlark(e)
+		}
+		out = starlark.NewList(elems)
+	case []map[string]any:
+		elems := make([]starlark.Value, len(v))
+		for i, e := range v {
+			elems[i] = toStarlark(e)
+		}
+		out = starlark.NewList(elems)
+	case map[string]any:
+		d := starlark.NewDict(len(v))
+		for k, e := range v {
+			d.SetKey(starlark.String(k), toStarlark(e))
+		}
+		out = d
+	default:
+		out = starlark.String(fmt.Sprint(v))
+	}
+	out.Freeze()
+	return out
+}
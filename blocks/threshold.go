@@ -0,0 +1,77 @@
+package blocks
+
+import (
+	"time"
+
+	"swaystats/config"
+	"swaystats/events"
+	"swaystats/theme"
+)
+
+// threshold turns a stream of percentage samples into a severity with
+// hysteresis (separate clear levels) and sustain durations ("for"), so a
+// value hovering around a threshold does not make the block flicker.
+// Shared by every percentage-based provider; level changes are published on
+// the events bus.
+type threshold struct {
+	module, instance       string
+	warn, danger           float64
+	warnClear, dangerClear float64
+	warnFor, dangerFor     int64 // ns the value must stay above before the level is entered
+	warnSince, dangerSince int64 // first sample at/above the level in the current streak (0 = none)
+	level                  theme.Severity
+}
+
+func newThreshold(t config.Thresholds, module, instance string) *threshold {
+	return &threshold{
+		module:      module,
+		instance:    instance,
+		warn:        float64(t.WarnPercent),
+		danger:      float64(t.DangerPercent),
+		warnClear:   float64(t.WarnClearPercent),
+		dangerClear: float64(t.DangerClearPercent),
+		warnFor:     int64(time.Duration(t.WarnForSec) * time.Second),
+		dangerFor:   int64(time.Duration(t.DangerForSec) * time.Second),
+	}
+}
+
+// Update feeds a sample taken at now (unix ns) and returns the resulting severity.
+func (t *threshold) Update(value float64, now int64) theme.Severity {
+	t.dangerSince = streak(value >= t.danger, t.dangerSince, now)
+	t.warnSince = streak(value >= t.warn, t.warnSince, now)
+	dangerOn := t.level == theme.SeverityDanger && value >= t.dangerClear ||
+		t.dangerSince != 0 && now-t.dangerSince >= t.dangerFor
+	warnOn := t.level >= theme.SeverityWarn && value >= t.warnClear ||
+		t.warnSince != 0 && now-t.warnSince >= t.warnFor
+	level := theme.SeverityNormal
+	switch {
+	case dangerOn:
+		level = theme.SeverityDanger
+	case warnOn:
+		level = theme.SeverityWarn
+	}
+	if level != t.level {
+		events.Publish(events.SeverityChange{
+			Module:   t.module,
+			Instance: t.instance,
+			From:     t.level,
+			To:       level,
+			Value:    value,
+			At:       time.Unix(0, now),
+		})
+		t.level = level
+	}
+	return t.level
+}
+
+// streak returns the start of the current run of above-level samples.
+func streak(above bool, since, now int64) int64 {
+	switch {
+	case !above:
+		return 0
+	case since == 0:
+		return now
+	default:
+		return since
+	}
+}
@@ -1,53 +1,89 @@
 package blocks
 
 import (
+	"log/slog"
 	"time"
 
+	"swaystats/clicks"
 	"swaystats/config"
 )
 
 // TimeProvider implements Provider for the clock.
+// It holds one or more views (format + zone); clicks cycle between them.
 type TimeProvider struct {
-	interval int64 // desired minimum refresh interval (ns)
-	format   string
-	lastSec  int64 // last rendered wall-clock second
+	views    []timeView
+	current  int   // index into views
+	lastKey  int64 // last rendered now/granularity bucket
 	blk      Block
+	instance string
 }
 
-func NewTimeProvider(interval time.Duration, format string) *TimeProvider {
-	tp := &TimeProvider{interval: int64(interval), format: format}
-	now := time.Now()
-	tp.lastSec = now.Unix() - 1 // force first refresh
-	tp.MaybeRefresh(now.UnixNano())
+// timeView is one way of rendering the clock.
+type timeView struct {
+	format      clockFormat
+	loc         *time.Location
+	granularity int64 // ns between visible changes (1s or 1min)
+}
+
+func NewTimeProvider(mcfg config.TimeModule, instance string) *TimeProvider {
+	names := lookupLocale(mcfg.Locale)
+	tp := &TimeProvider{instance: instance}
+	tp.views = append(tp.views, newTimeView(mcfg.Format, mcfg.Timezone, names))
+	for _, v := range mcfg.Cycle {
+		format, zone := v.Format, v.Timezone
+		if format == "" {
+			format = mcfg.Format
+		}
+		if zone == "" {
+			zone = mcfg.Timezone
+		}
+		tp.views = append(tp.views, newTimeView(format, zone, names))
+	}
+	tp.lastKey = -1 // force first refresh
+	tp.MaybeRefresh(time.Now().UnixNano())
 	return tp
 }
 
+func newTimeView(format, zone string, names *localeNames) timeView {
+	loc := time.Local
+	if zone != "" {
+		l, err := time.LoadLocation(zone)
+		if err != nil {
+			slog.Warn("unknown timezone; using local", "module", "time", "timezone", zone, "err", err)
+		} else {
+			loc = l
+		}
+	}
+	f := newClockFormat(format, names)
+	return timeView{format: f, loc: loc, granularity: int64(f.granularity())}
+}
+
 func init() {
 	Register(ProviderSpec{
 		Name:   "time",
-		Enable: func(cfg *config.Config) bool { return cfg.Modules.Time.Enabled },
-		Build:  func(cfg *config.Config) Provider { return NewTimeProvider(time.Second, cfg.Modules.Time.Format) },
+		Enable: func(cfg *config.Config, instance string) bool { return cfg.TimeFor(instance).Enabled },
+		Build: func(cfg *config.Config, instance string) Provider {
+			return NewTimeProvider(cfg.TimeFor(instance), instance)
+		},
 	})
 }
 
 func (t *TimeProvider) Name() string { return "time" }
 
 func (t *TimeProvider) MaybeRefresh(now int64) bool {
-	sec := now / int64(time.Second)
-	if sec == t.lastSec { // same second, nothing to do
+	v := t.views[t.current]
+	key := now / v.granularity
+	if key == t.lastKey { // same second (or minute), nothing to do
 		return false
 	}
-	// Optionally enforce minimum interval (useful if TickHz>1) by checking now - lastSec*1s
-	if t.interval > 0 && now-(t.lastSec*int64(time.Second)) < t.interval {
-		// Even if second changed, respect minimum custom interval (rare for clock)
-	}
-	t.lastSec = sec
-	txt := time.Unix(sec, 0).Format(t.format)
+	t.lastKey = key
+	txt := v.format.format(time.Unix(0, key*v.granularity).In(v.loc))
 	if t.blk.FullText == txt { // defensive
 		return false
 	}
 	t.blk = Block{
 		Name:                "time",
+		Instance:            t.instance,
 		FullText:            txt,
 		Separator:           false,
 		SeparatorBlockWidth: SeparatorWidth,
@@ -56,3 +92,20 @@ func (t *TimeProvider) MaybeRefresh(now int64) bool {
 }
 
 func (t *TimeProvider) Current() Block { return t.blk }
+
+// HandleClick cycles views: left click forward, right click backward.
+func (t *TimeProvider) HandleClick(c clicks.Click, now int64) bool {
+	if len(t.views) < 2 {
+		return false
+	}
+	switch c.Button {
+	case clicks.ButtonLeft:
+		t.current = (t.current + 1) % len(t.views)
+	case clicks.ButtonRight:
+		t.current = (t.current + len(t.views) - 1) % len(t.views)
+	default:
+		return false
+	}
+	t.lastKey = -1
+	return t.MaybeRefresh(now)
+}
@@ -0,0 +1,279 @@
+package blocks
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockFormat renders a time using either a Go layout or a strftime pattern
+// (any format containing '%'), with month/day names taken from names.
+type clockFormat struct {
+	layout   string
+	strftime bool
+	names    *localeNames // nil means Go's English names
+}
+
+func newClockFormat(layout string, names *localeNames) clockFormat {
+	return clockFormat{layout: layout, strftime: strings.Contains(layout, "%"), names: names}
+}
+
+func (f clockFormat) format(t time.Time) string {
+	if f.strftime {
+		return strftime(t, f.layout, f.names)
+	}
+	if f.names == nil {
+		return t.Format(f.layout)
+	}
+	return formatLocalized(t, f.layout, f.names)
+}
+
+// granularity returns time.Minute if the rendered text never depends on the
+// seconds (or finer) fields, otherwise time.Second.
+func (f clockFormat) granularity() time.Duration {
+	a := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+	b := time.Date(2006, 1, 2, 15, 4, 37, 500_000_000, time.UTC)
+	if f.format(a) == f.format(b) {
+		return time.Minute
+	}
+	return time.Second
+}
+
+// formatLocalized formats a Go layout, substituting month and weekday names.
+// Name tokens follow the same rules as time.Format: "January"/"Monday" always
+// match, "Jan"/"Mon" only when not followed by a lowercase letter.
+func formatLocalized(t time.Time, layout string, names *localeNames) string {
+	var sb strings.Builder
+	start := 0
+	for i := 0; i < len(layout); i++ {
+		var repl string
+		n := 0
+		switch {
+		case strings.HasPrefix(layout[i:], "January"):
+			repl, n = names.months[t.Month()-1], 7
+		case strings.HasPrefix(layout[i:], "Monday"):
+			repl, n = names.days[t.Weekday()], 6
+		case strings.HasPrefix(layout[i:], "Jan") && !startsLower(layout[i+3:]):
+			repl, n = names.shortMonths[t.Month()-1], 3
+		case strings.HasPrefix(layout[i:], "Mon") && !startsLower(layout[i+3:]):
+			repl, n = names.shortDays[t.Weekday()], 3
+		default:
+			continue
+		}
+		sb.WriteString(t.Format(layout[start:i]))
+		sb.WriteString(repl)
+		i += n - 1
+		start = i + 1
+	}
+	sb.WriteString(t.Format(layout[start:]))
+	return sb.String()
+}
+
+func startsLower(s string) bool {
+	return len(s) > 0 && s[0] >= 'a' && s[0] <= 'z'
+}
+
+// strftime implements the common POSIX conversion specifiers. Unknown
+// specifiers are emitted verbatim.
+func strftime(t time.Time, pattern string, names *localeNames) string {
+	if names == nil {
+		names = &englishNames
+	}
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c != '%' || i+1 >= len(pattern) {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 'a':
+			sb.WriteString(names.shortDays[t.Weekday()])
+		case 'A':
+			sb.WriteString(names.days[t.Weekday()])
+		case 'b', 'h':
+			sb.WriteString(names.shortMonths[t.Month()-1])
+		case 'B':
+			sb.WriteString(names.months[t.Month()-1])
+		case 'c':
+			sb.WriteString(strftime(t, "%a %b %e %H:%M:%S %Y", names))
+		case 'C':
+			sb.WriteString(pad2(t.Year() / 100))
+		case 'd':
+			sb.WriteString(pad2(t.Day()))
+		case 'D':
+			sb.WriteString(t.Format("01/02/06"))
+		case 'e':
+			sb.WriteString(t.Format("_2"))
+		case 'F':
+			sb.WriteString(t.Format("2006-01-02"))
+		case 'g':
+			y, _ := t.ISOWeek()
+			sb.WriteString(pad2(y % 100))
+		case 'G':
+			y, _ := t.ISOWeek()
+			sb.WriteString(strconv.Itoa(y))
+		case 'H':
+			sb.WriteString(pad2(t.Hour()))
+		case 'I':
+			sb.WriteString(t.Format("03"))
+		case 'j':
+			sb.WriteString(t.Format("002"))
+		case 'k':
+			sb.WriteString(spacePad2(t.Hour()))
+		case 'l':
+			sb.WriteString(spacePad2((t.Hour()+11)%12 + 1))
+		case 'm':
+			sb.WriteString(pad2(int(t.Month())))
+		case 'M':
+			sb.WriteString(pad2(t.Minute()))
+		case 'n':
+			sb.WriteByte('\n')
+		case 'p':
+			sb.WriteString(t.Format("PM"))
+		case 'P':
+			sb.WriteString(t.Format("pm"))
+		case 'r':
+			sb.WriteString(t.Format("03:04:05 PM"))
+		case 'R':
+			sb.WriteString(t.Format("15:04"))
+		case 's':
+			sb.WriteString(strconv.FormatInt(t.Unix(), 10))
+		case 'S':
+			sb.WriteString(pad2(t.Second()))
+		case 't':
+			sb.WriteByte('\t')
+		case 'T':
+			sb.WriteString(t.Format("15:04:05"))
+		case 'u':
+			sb.WriteString(strconv.Itoa((int(t.Weekday())+6)%7 + 1))
+		case 'U':
+			sb.WriteString(pad2((t.YearDay() + 6 - int(t.Weekday())) / 7))
+		case 'V':
+			_, w := t.ISOWeek()
+			sb.WriteString(pad2(w))
+		case 'w':
+			sb.WriteString(strconv.Itoa(int(t.Weekday())))
+		case 'W':
+			sb.WriteString(pad2((t.YearDay() + 6 - (int(t.Weekday())+6)%7) / 7))
+		case 'x':
+			sb.WriteString(t.Format("01/02/06"))
+		case 'X':
+			sb.WriteString(t.Format("15:04:05"))
+		case 'y':
+			sb.WriteString(pad2(t.Year() % 100))
+		case 'Y':
+			sb.WriteString(strconv.Itoa(t.Year()))
+		case 'z':
+			sb.WriteString(t.Format("-0700"))
+		case 'Z':
+			sb.WriteString(t.Format("MST"))
+		case '%':
+			sb.WriteByte('%')
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(pattern[i])
+		}
+	}
+	return sb.String()
+}
+
+func pad2(n int) string {
+	if n < 10 {
+		return "0" + strconv.Itoa(n)
+	}
+	return strconv.Itoa(n)
+}
+
+func spacePad2(n int) string {
+	if n < 10 {
+		return " " + strconv.Itoa(n)
+	}
+	return strconv.Itoa(n)
+}
+
+// localeNames holds month and weekday names (weekdays start on Sunday, as time.Weekday).
+type localeNames struct {
+	months      [12]string
+	shortMonths [12]string
+	days        [7]string
+	shortDays   [7]string
+}
+
+var englishNames = localeNames{
+	months:      [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	shortMonths: [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+	days:        [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+	shortDays:   [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+}
+
+// locales maps a language code to its names. Kept deliberately small; unknown
+// languages fall back to English.
+var locales = map[string]*localeNames{
+	"en": &englishNames,
+	"de": {
+		months:      [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		shortMonths: [12]string{"Jan", "Feb", "Mär", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez"},
+		days:        [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		shortDays:   [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+	},
+	"fr": {
+		months:      [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		shortMonths: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		days:        [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		shortDays:   [7]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+	},
+	"es": {
+		months:      [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		shortMonths: [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		days:        [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		shortDays:   [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+	},
+	"it": {
+		months:      [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		shortMonths: [12]string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		days:        [7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+		shortDays:   [7]string{"dom", "lun", "mar", "mer", "gio", "ven", "sab"},
+	},
+	"nl": {
+		months:      [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		shortMonths: [12]string{"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		days:        [7]string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+		shortDays:   [7]string{"zo", "ma", "di", "wo", "do", "vr", "za"},
+	},
+	"pt": {
+		months:      [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		shortMonths: [12]string{"jan", "fev", "mar", "abr", "mai", "jun", "jul", "ago", "set", "out", "nov", "dez"},
+		days:        [7]string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"},
+		shortDays:   [7]string{"dom", "seg", "ter", "qua", "qui", "sex", "sáb"},
+	},
+	"sv": {
+		months:      [12]string{"januari", "februari", "mars", "april", "maj", "juni", "juli", "augusti", "september", "oktober", "november", "december"},
+		shortMonths: [12]string{"jan", "feb", "mar", "apr", "maj", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		days:        [7]string{"söndag", "måndag", "tisdag", "onsdag", "torsdag", "fredag", "lördag"},
+		shortDays:   [7]string{"sön", "mån", "tis", "ons", "tor", "fre", "lör"},
+	},
+	"pl": {
+		months:      [12]string{"stycznia", "lutego", "marca", "kwietnia", "maja", "czerwca", "lipca", "sierpnia", "września", "października", "listopada", "grudnia"},
+		shortMonths: [12]string{"sty", "lut", "mar", "kwi", "maj", "cze", "lip", "sie", "wrz", "paź", "lis", "gru"},
+		days:        [7]string{"niedziela", "poniedziałek", "wtorek", "środa", "czwartek", "piątek", "sobota"},
+		shortDays:   [7]string{"niedz.", "pon.", "wt.", "śr.", "czw.", "pt.", "sob."},
+	},
+}
+
+// lookupLocale resolves a locale such as "de_DE.UTF-8" to month/day names.
+// The environment is not consulted, so a config without locale keeps English
+// names. Returns nil for English (or empty or unknown languages) so Go
+// layouts keep using time.Format directly.
+func lookupLocale(locale string) *localeNames {
+	lang := strings.ToLower(locale)
+	if i := strings.IndexAny(lang, "_.@-"); i >= 0 {
+		lang = lang[:i]
+	}
+	names, ok := locales[lang]
+	if !ok || names == &englishNames {
+		return nil
+	}
+	return names
+}
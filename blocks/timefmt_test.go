@@ -0,0 +1,23 @@
+package blocks
+
+import "testing"
+
+func TestLookupLocale(t *testing.T) {
+	t.Setenv("LC_ALL", "de_DE.UTF-8")
+	t.Setenv("LANG", "de_DE.UTF-8")
+	tests := []struct {
+		locale string
+		want   *localeNames
+	}{
+		{"", nil},
+		{"en_US", nil},
+		{"xx_YY", nil},
+		{"de_DE.UTF-8", locales["de"]},
+		{"FR", locales["fr"]},
+	}
+	for _, tt := range tests {
+		if got := lookupLocale(tt.locale); got != tt.want {
+			t.Errorf("lookupLocale(%q) = %p, want %p", tt.locale, got, tt.want)
+		}
+	}
+}
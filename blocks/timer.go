@@ -0,0 +1,285 @@
+package blocks
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"swaystats/clicks"
+	"swaystats/config"
+	"swaystats/theme"
+)
+
+// TimerProvider is a click-controlled pomodoro timer, countdown or stopwatch.
+//
+//	left click    start / pause
+//	right click   reset
+//	middle click  skip to the next pomodoro phase
+//	scroll        add / remove step_min minutes
+//
+// State is persisted so reloads and restarts keep a running timer.
+type TimerProvider struct {
+	mcfg      config.TimerModule
+	instance  string
+	statePath string
+	st        timerState
+	lastSec   int64
+	blk       Block
+}
+
+// timerState is the persisted part of a timer. Times are unix nanoseconds.
+type timerState struct {
+	Mode      string `json:"mode"`
+	Phase     string `json:"phase"`      // work|short_break|long_break|countdown|stopwatch
+	Completed int    `json:"completed"`  // finished work phases in this pomodoro cycle
+	Duration  int64  `json:"duration"`   // phase length (0 for stopwatch)
+	Elapsed   int64  `json:"elapsed"`    // accumulated time before StartedAt
+	StartedAt int64  `json:"started_at"` // non-zero while running
+	Finished  bool   `json:"finished"`   // phase ended and not yet acknowledged by a click
+}
+
+var timerPhaseLabels = map[string]string{
+	"work":        "work",
+	"short_break": "break",
+	"long_break":  "long break",
+	"countdown":   "",
+	"stopwatch":   "",
+}
+
+func NewTimerProvider(mcfg config.TimerModule, instance string) *TimerProvider {
+	tp := &TimerProvider{mcfg: mcfg, instance: instance}
+	if dir := config.StateDir(); dir != "" {
+		tp.statePath = filepath.Join(dir, timerStateName(instance))
+	}
+	if !tp.load() || tp.st.Mode != mcfg.Mode {
+		tp.reset()
+	}
+	tp.MaybeRefresh(time.Now().UnixNano())
+	return tp
+}
+
+// timerStateName returns the state file name of an instance. The instance is
+// escaped, so a name like "../x" stays a file in the state directory.
+func timerStateName(instance string) string {
+	if instance == "" {
+		return "timer.json"
+	}
+	return "timer-" + url.PathEscape(instance) + ".json"
+}
+
+func init() {
+	Register(ProviderSpec{
+		Name:   "timer",
+		Enable: func(cfg *config.Config, instance string) bool { return cfg.TimerFor(instance).Enabled },
+		Build: func(cfg *config.Config, instance string) Provider {
+			return NewTimerProvider(cfg.TimerFor(instance), instance)
+		},
+	})
+}
+
+func (t *TimerProvider) Name() string { return "timer" }
+
+func (t *TimerProvider) MaybeRefresh(now int64) bool {
+	if t.st.Duration > 0 && t.st.StartedAt != 0 && t.elapsed(now) >= t.st.Duration {
+		t.finishPhase(now)
+	}
+	sec := now / int64(time.Second)
+	if sec == t.lastSec && t.blk.FullText != "" {
+		return false
+	}
+	t.lastSec = sec
+	blk := t.render(now)
+	if blk == t.blk {
+		return false
+	}
+	t.blk = blk
+	return true
+}
+
+func (t *TimerProvider) Current() Block { return t.blk }
+
+func (t *TimerProvider) HandleClick(c clicks.Click, now int64) bool {
+	step := minutes(t.mcfg.StepMin)
+	finished := t.st.Finished
+	t.st.Finished = false
+	switch c.Button {
+	case clicks.ButtonLeft:
+		if t.st.StartedAt != 0 {
+			t.st.Elapsed = t.elapsed(now)
+			t.st.StartedAt = 0
+		} else {
+			if t.st.Duration > 0 && t.st.Elapsed >= t.st.Duration {
+				t.st.Elapsed = 0 // restart a finished countdown
+			}
+			t.st.StartedAt = now
+		}
+	case clicks.ButtonRight:
+		t.reset()
+	case clicks.ButtonMiddle:
+		if t.st.Mode == "pomodoro" {
+			t.advance(now, t.st.StartedAt != 0)
+		}
+	case clicks.ButtonScrollUp:
+		if t.st.Duration > 0 {
+			t.st.Duration += step
+		}
+	case clicks.ButtonScrollDown:
+		if t.st.Duration > step && t.st.Duration-step > t.elapsed(now) {
+			t.st.Duration -= step
+		}
+	default:
+		t.st.Finished = finished
+		return false
+	}
+	t.save()
+	t.lastSec = 0
+	return t.MaybeRefresh(now)
+}
+
+func (t *TimerProvider) elapsed(now int64) int64 {
+	if t.st.StartedAt == 0 {
+		return t.st.Elapsed
+	}
+	return t.st.Elapsed + now - t.st.StartedAt
+}
+
+// reset returns to the first phase of the configured mode, stopped.
+func (t *TimerProvider) reset() {
+	t.st = timerState{Mode: t.mcfg.Mode}
+	switch t.mcfg.Mode {
+	case "countdown":
+		t.st.Phase = "countdown"
+		t.st.Duration = minutes(t.mcfg.CountdownMin)
+	case "stopwatch":
+		t.st.Phase = "stopwatch"
+	default:
+		t.st.Phase = "work"
+		t.st.Duration = minutes(t.mcfg.WorkMin)
+	}
+	t.save()
+}
+
+// finishPhase fires the end-of-phase hook and, for pomodoro, moves on. The
+// block is marked finished only if the timer stopped; a phase started by
+// auto_advance runs normally.
+func (t *TimerProvider) finishPhase(now int64) {
+	ended := t.st.Phase
+	runCommand(t.mcfg.OnPhaseEnd, "SWAYSTATS_TIMER_PHASE="+ended)
+	if t.st.Mode == "pomodoro" {
+		// Continue from the exact end of the phase so auto-advance does not drift.
+		end := t.st.StartedAt + t.st.Duration - t.st.Elapsed
+		t.advance(end, t.mcfg.AutoAdvance)
+		if !t.mcfg.AutoAdvance || now-end >= t.st.Duration {
+			t.st.StartedAt = 0
+			t.st.Elapsed = 0
+		}
+	} else {
+		t.st.Elapsed = t.st.Duration
+		t.st.StartedAt = 0
+	}
+	t.st.Finished = t.st.StartedAt == 0
+	t.save()
+}
+
+// advance moves a pomodoro to its next phase, running it from at if run is set.
+func (t *TimerProvider) advance(at int64, run bool) {
+	if t.st.Phase == "work" {
+		t.st.Completed++
+		if t.st.Completed >= t.mcfg.LongBreakEvery {
+			t.st.Phase = "long_break"
+			t.st.Duration = minutes(t.mcfg.LongBreakMin)
+			t.st.Completed = 0
+		} else {
+			t.st.Phase = "short_break"
+			t.st.Duration = minutes(t.mcfg.ShortBreakMin)
+		}
+	} else {
+		t.st.Phase = "work"
+		t.st.Duration = minutes(t.mcfg.WorkMin)
+	}
+	t.st.Elapsed = 0
+	t.st.StartedAt = 0
+	if run {
+		t.st.StartedAt = at
+	}
+}
+
+func (t *TimerProvider) render(now int64) Block {
+	shown := t.elapsed(now)
+	if t.st.Duration > 0 {
+		shown = t.st.Duration - shown
+		if shown < 0 {
+			shown = 0
+		}
+	}
+	text := t.mcfg.Prefix
+	if label := timerPhaseLabels[t.st.Phase]; label != "" {
+		text += " " + label
+	}
+	text += " " + formatClock(time.Duration(shown))
+	if t.st.StartedAt == 0 && t.st.Elapsed > 0 && !t.st.Finished {
+		text += " ⏸"
+	}
+	blk := Block{Name: "timer", Instance: t.instance, FullText: text, Separator: false, SeparatorBlockWidth: SeparatorWidth, Prefix: t.mcfg.Prefix}
+	if t.st.Finished {
+		blk.Urgent = true
+		blk.Severity = theme.SeverityWarn
+		if color, ok := theme.ColorFor(theme.SeverityWarn); ok {
+			blk.Color = color
+		}
+	}
+	return blk
+}
+
+func (t *TimerProvider) load() bool {
+	if t.statePath == "" {
+		return false
+	}
+	data, err := os.ReadFile(t.statePath)
+	if err != nil {
+		return false
+	}
+	if err := json.Unmarshal(data, &t.st); err != nil {
+		slog.Warn("load timer state", "module", "timer", "instance", t.instance, "path", t.statePath, "err", err)
+		return false
+	}
+	return true
+}
+
+// save writes state atomically (temp file + rename); failures are logged only.
+func (t *TimerProvider) save() {
+	if t.statePath == "" {
+		return
+	}
+	data, err := json.Marshal(t.st)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(t.statePath), 0o755); err != nil {
+		slog.Warn("timer state dir", "module", "timer", "instance", t.instance, "err", err)
+		return
+	}
+	tmp := t.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		slog.Warn("save timer state", "module", "timer", "instance", t.instance, "err", err)
+		return
+	}
+	if err := os.Rename(tmp, t.statePath); err != nil {
+		slog.Warn("save timer state", "module", "timer", "instance", t.instance, "err", err)
+	}
+}
+
+func minutes(n int) int64 { return int64(time.Duration(n) * time.Minute) }
+
+// formatClock renders d as MM:SS, or H:MM:SS from one hour up.
+func formatClock(d time.Duration) string {
+	s := int64(d / time.Second)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s%3600/60, s%60)
+	}
+	return fmt.Sprintf("%02d:%02d", s/60, s%60)
+}
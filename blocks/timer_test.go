@@ -0,0 +1,101 @@
+package blocks
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"swaystats/config"
+)
+
+func TestTimerStateName(t *testing.T) {
+	tests := []struct{ instance, want string }{
+		{"", "timer.json"},
+		{"tea", "timer-tea.json"},
+		{"deep_work-2", "timer-deep_work-2.json"},
+		{"../../.bashrc", "timer-..%2F..%2F.bashrc.json"},
+		{"a/b", "timer-a%2Fb.json"},
+		{"..", "timer-...json"},
+	}
+	for _, tt := range tests {
+		got := timerStateName(tt.instance)
+		if got != tt.want {
+			t.Errorf("timerStateName(%q) = %q, want %q", tt.instance, got, tt.want)
+		}
+		if dir := "/state"; filepath.Dir(filepath.Join(dir, got)) != dir {
+			t.Errorf("timerStateName(%q) = %q leaves the state directory", tt.instance, got)
+		}
+	}
+}
+
+func TestTimerFinishPhase(t *testing.T) {
+	mcfg := config.TimerModule{Mode: "pomodoro", WorkMin: 25, ShortBreakMin: 5, LongBreakMin: 15, LongBreakEvery: 2}
+	start := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC).UnixNano()
+	workEnd := start + minutes(25)
+	tests := []struct {
+		name          string
+		auto          bool
+		mode          string
+		completed     int
+		now           int64
+		phase         string
+		duration      int64
+		startedAt     int64 // 0: stopped
+		wantCompleted int
+		finished      bool
+		wantElapsed   int64
+	}{
+		{"short break, stopped", false, "pomodoro", 0, workEnd + 2*secNs, "short_break", minutes(5), 0, 1, true, 0},
+		{"long break after long_break_every", false, "pomodoro", 1, workEnd, "long_break", minutes(15), 0, 0, true, 0},
+		{"auto-advance starts at the phase end", true, "pomodoro", 0, workEnd + 3*secNs, "short_break", minutes(5), workEnd, 1, false, 0},
+		{"auto-advance long break", true, "pomodoro", 1, workEnd + secNs, "long_break", minutes(15), workEnd, 0, false, 0},
+		{"auto-advance after a gap stops", true, "pomodoro", 0, workEnd + minutes(5), "short_break", minutes(5), 0, 1, true, 0},
+		{"auto-advance just inside the gap", true, "pomodoro", 0, workEnd + minutes(5) - secNs, "short_break", minutes(5), workEnd, 1, false, 0},
+		{"countdown", false, "countdown", 0, workEnd, "countdown", minutes(25), 0, 0, true, minutes(25)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mcfg
+			m.AutoAdvance, m.Mode = tt.auto, tt.mode
+			tp := &TimerProvider{mcfg: m}
+			phase := "work"
+			if tt.mode == "countdown" {
+				phase = "countdown"
+			}
+			tp.st = timerState{Mode: tt.mode, Phase: phase, Completed: tt.completed, Duration: minutes(25), StartedAt: start}
+			tp.finishPhase(tt.now)
+			st := tp.st
+			if st.Phase != tt.phase || st.Duration != tt.duration || st.StartedAt != tt.startedAt ||
+				st.Completed != tt.wantCompleted || st.Finished != tt.finished || st.Elapsed != tt.wantElapsed {
+				t.Errorf("state = %+v", st)
+			}
+			if blk := tp.render(tt.now); blk.Urgent != tt.finished {
+				t.Errorf("urgent = %v, want %v", blk.Urgent, tt.finished)
+			}
+		})
+	}
+}
+
+// TestTimerAutoAdvanceCycle runs a pomodoro through several phases on
+// MaybeRefresh alone: no phase leaves the block urgent and no time is lost.
+func TestTimerAutoAdvanceCycle(t *testing.T) {
+	mcfg := config.TimerModule{Mode: "pomodoro", WorkMin: 25, ShortBreakMin: 5, LongBreakMin: 15, LongBreakEvery: 2, AutoAdvance: true}
+	tp := &TimerProvider{mcfg: mcfg}
+	tp.reset()
+	start := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC).UnixNano()
+	tp.st.StartedAt = start
+	at := start
+	for _, want := range []struct {
+		phase string
+		min   int
+	}{{"short_break", 25}, {"work", 5}, {"long_break", 25}, {"work", 15}} {
+		at += minutes(want.min)
+		tp.MaybeRefresh(at + 700*int64(time.Millisecond)) // ticks land a little late
+		if tp.st.Phase != want.phase || tp.st.StartedAt != at || tp.blk.Urgent {
+			t.Fatalf("after %d min: phase %s started %v urgent %v, want %s started at the phase end",
+				want.min, tp.st.Phase, time.Duration(tp.st.StartedAt-start), tp.blk.Urgent, want.phase)
+		}
+	}
+}
+
+const secNs = int64(time.Second)
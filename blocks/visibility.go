@@ -0,0 +1,126 @@
+package blocks
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"swaystats/config"
+	"swaystats/expr"
+	"swaystats/theme"
+)
+
+// Visibility applies the show_when rules of the configured modules to a row.
+// Rules see every block of the row, hidden or not: a module's kind (its
+// first block) or kind.instance evaluates to its percentage. A rule that
+// fails to parse or evaluate is logged once and leaves its block visible.
+type Visibility struct {
+	rules  map[string]*expr.Expr // parsed rules by source; nil if invalid
+	failed map[string]bool       // rules whose last evaluation failed (logged)
+	hidden map[config.ModuleRef]bool
+}
+
+func NewVisibility() *Visibility {
+	return &Visibility{rules: map[string]*expr.Expr{}, failed: map[string]bool{}, hidden: map[config.ModuleRef]bool{}}
+}
+
+// Filter returns the visible blocks of row (one block per provider) and
+// whether any block appeared or disappeared since the last call.
+func (v *Visibility) Filter(cfg *config.Config, providers []Provider, row []Block) ([]Block, bool) {
+	var env *expr.Env
+	changed := false
+	hidden := map[config.ModuleRef]bool{}
+	out := row[:0:0]
+	for i, blk := range row {
+		ref := config.ModuleRef{Kind: providers[i].Name(), Instance: blk.Instance}
+		rule := v.rule(cfg.CommonFor(ref).ShowWhen)
+		if rule != nil {
+			if env == nil {
+				env = newEnv(providers, row)
+			}
+			if !v.eval(rule, env) {
+				hidden[ref] = true
+			}
+		}
+		if hidden[ref] != v.hidden[ref] {
+			changed = true
+		}
+		if !hidden[ref] {
+			out = append(out, blk)
+		}
+	}
+	changed = changed || len(hidden) != len(v.hidden)
+	v.hidden = hidden
+	return out, changed
+}
+
+// rule returns the parsed rule for src, or nil if src is empty or invalid.
+func (v *Visibility) rule(src string) *expr.Expr {
+	if strings.TrimSpace(src) == "" {
+		return nil
+	}
+	if e, ok := v.rules[src]; ok {
+		return e
+	}
+	e, err := expr.Parse(src)
+	if err != nil {
+		slog.Warn("show_when ignored", "rule", src, "err", err)
+	}
+	v.rules[src] = e
+	return e
+}
+
+// eval evaluates rule; errors count as true.
+func (v *Visibility) eval(rule *expr.Expr, env *expr.Env) bool {
+	show, err := rule.Bool(env)
+	if err != nil {
+		if !v.failed[rule.String()] {
+			slog.Warn("show_when failed; showing block", "rule", rule.String(), "err", err)
+			v.failed[rule.String()] = true
+		}
+		return true
+	}
+	v.failed[rule.String()] = false
+	return show
+}
+
+// newEnv exposes the row's values on top of the system facts (see expr.System).
+func newEnv(providers []Provider, row []Block) *expr.Env {
+	blocks := map[string]Block{}
+	for i, blk := range row {
+		kind := providers[i].Name()
+		if _, seen := blocks[kind]; !seen {
+			blocks[kind] = blk
+		}
+		if blk.Instance != "" {
+			blocks[kind+"."+blk.Instance] = blk
+		}
+	}
+	env := expr.System(time.Now())
+	env.Vars["warn"] = func() expr.Value { return float64(theme.SeverityWarn) }
+	env.Vars["danger"] = func() expr.Value { return float64(theme.SeverityDanger) }
+	lookup := func(fn string, args []expr.Value) (Block, error) {
+		name, err := expr.StringArg(fn, args)
+		if err != nil {
+			return Block{}, err
+		}
+		blk, ok := blocks[name]
+		if !ok {
+			return Block{}, fmt.Errorf("%s: no module %q", fn, name)
+		}
+		return blk, nil
+	}
+	env.Funcs["severity"] = func(args []expr.Value) (expr.Value, error) {
+		blk, err := lookup("severity", args)
+		return float64(blk.Severity), err
+	}
+	env.Funcs["text"] = func(args []expr.Value) (expr.Value, error) {
+		blk, err := lookup("text", args)
+		return blk.Text(), err
+	}
+	for name, blk := range blocks {
+		env.Vars[name] = func() expr.Value { return float64(blk.Percentage) }
+	}
+	return env
+}
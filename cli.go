@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime/debug"
+
+	"swaystats/config"
+	"swaystats/logfile"
+)
+
+// options holds the command-line flags shared by every subcommand.
+type options struct {
+	configPath   string
+	tickHz       int
+	logFile      string
+	logLevel     string
+	noClicks     bool
+	output       string
+	json         bool   // once / watch only
+	from, to     string // convert / import only
+	printDefault bool
+	version      bool
+	args         []string // positional arguments
+}
+
+// parseFlags parses args for cmd. It handles --version and
+// --print-default-config itself and exits afterwards.
+func parseFlags(cmd string, args []string) *options {
+	o := &options{}
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	fs.StringVar(&o.configPath, "config", "", "config file (default $XDG_CONFIG_HOME/swaystats/config.{toml,yaml,json})")
+	fs.IntVar(&o.tickHz, "tick-hz", 0, "override tick_hz from the config (1..20)")
+	fs.StringVar(&o.logFile, "log-file", "", "append logs to this file instead of stderr (default from config)")
+	fs.StringVar(&o.logLevel, "log-level", "", "minimum log level: debug, info, warn, error (default from config, else info)")
+	fs.BoolVar(&o.noClicks, "no-clicks", false, "do not request or read click events")
+	fs.BoolVar(&o.printDefault, "print-default-config", false, "print the default config as commented TOML and exit")
+	fs.BoolVar(&o.version, "version", false, "print version and build info and exit")
+	switch cmd {
+	case "once", "watch":
+		fs.StringVar(&o.output, "output", "plain", "row format when not using --json: i3bar, waybar, lemonbar, polybar, tmux, plain")
+		fs.BoolVar(&o.json, "json", false, "write JSON state instead of a text row")
+	case "convert":
+		fs.StringVar(&o.from, "from", "", "input format: toml, yaml, json (default from the file extension)")
+		fs.StringVar(&o.to, "to", "toml", "output format: toml, yaml, json")
+	case "import":
+		fs.StringVar(&o.from, "from", "", "format of the file to import: i3status, i3blocks")
+	default:
+		fs.StringVar(&o.output, "output", "", "output format: i3bar, waybar, lemonbar, polybar, tmux, plain (default from config)")
+	}
+	fs.Parse(args)
+	o.args = fs.Args()
+	switch {
+	case o.version:
+		fmt.Println(versionString())
+		os.Exit(0)
+	case o.printDefault:
+		if err := config.WriteDefault(os.Stdout); err != nil {
+			slog.Error("print default config", "err", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	return o
+}
+
+// loadConfig loads the config chosen by --config, applies flag overrides and
+// sets up logging, so config problems already reach the configured log.
+func (o *options) loadConfig() *config.Config {
+	cfg, err := config.Load(o.configPath)
+	o.apply(cfg)
+	o.setupLogging(cfg.Log)
+	if err != nil {
+		slog.Warn("config", "path", o.configPath, "err", err)
+	}
+	return cfg
+}
+
+// apply applies flag overrides to a freshly loaded config.
+func (o *options) apply(cfg *config.Config) {
+	if o.tickHz > 0 {
+		cfg.TickHz = o.tickHz
+	}
+}
+
+// setupLogging installs the default slog logger from the [log] table and
+// the flags, writing to a rotated file or stderr; stdout stays reserved for
+// the bar. Called once at startup.
+func (o *options) setupLogging(lc config.Log) {
+	level := lc.Level
+	if o.logLevel != "" {
+		level = o.logLevel
+	}
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		fmt.Fprintf(os.Stderr, "swaystats: bad log level %q; using info\n", level)
+		lvl = slog.LevelInfo
+	}
+	path := lc.LogPath()
+	if o.logFile != "" {
+		path = config.ExpandHome(o.logFile)
+	}
+	var w io.Writer = os.Stderr
+	if path != "" {
+		f, err := logfile.Open(path, int64(lc.MaxSizeKB)*1024, lc.Keep)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "swaystats: log file: %v; logging to stderr\n", err)
+		} else {
+			w = f
+		}
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: lvl})))
+}
+
+// versionString describes the binary from its embedded build info.
+func versionString() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "swaystats (unknown version)"
+	}
+	version := bi.Main.Version
+	if version == "" {
+		version = "(devel)"
+	}
+	var rev, at string
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			rev = s.Value
+			if len(rev) > 12 {
+				rev = rev[:12]
+			}
+		case "vcs.time":
+			at = s.Value
+		}
+	}
+	out := "swaystats " + version
+	if rev != "" {
+		out += fmt.Sprintf(" (%s %s)", rev, at)
+	}
+	return out + " " + bi.GoVersion
+}
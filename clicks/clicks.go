@@ -4,7 +4,7 @@ import (
 	"bufio"
 	"encoding/json"
 	"io"
-	"log"
+	"log/slog"
 )
 
 // Click represents a click event fed by swaybar back into stdin.
@@ -17,6 +17,15 @@ type Click struct {
 	Modifiers []string `json:"modifiers"`
 }
 
+// Mouse buttons as reported by swaybar.
+const (
+	ButtonLeft       = 1
+	ButtonMiddle     = 2
+	ButtonRight      = 3
+	ButtonScrollUp   = 4
+	ButtonScrollDown = 5
+)
+
 // Read consumes newline-delimited JSON click events, emitting them onto out.
 // It drops events if the channel is full to avoid blocking the main loop.
 func Read(r io.Reader, out chan<- Click) {
@@ -24,7 +33,7 @@ func Read(r io.Reader, out chan<- Click) {
 	for sc.Scan() {
 		var c Click
 		if err := json.Unmarshal(sc.Bytes(), &c); err != nil {
-			log.Printf("click parse: %v", err)
+			slog.Warn("click parse", "err", err)
 			continue
 		}
 		select {
@@ -34,6 +43,6 @@ func Read(r io.Reader, out chan<- Click) {
 		}
 	}
 	if err := sc.Err(); err != nil {
-		log.Printf("click scanner: %v", err)
+		slog.Error("click scanner", "err", err)
 	}
 }
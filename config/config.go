@@ -5,66 +5,265 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
 
 type Config struct {
-	TickHz      int                 `toml:"tick_hz"`
-	Modules     Modules             `toml:"modules"`
-	moduleOrder []string            // order of module tables as they appeared in TOML
-	present     map[string]struct{} // modules explicitly present in the file
-	SourcePath  string              // filesystem path the config was loaded from (empty if defaults only)
+	TickHz        int                 `toml:"tick_hz" schema:"min=1,max=20"`
+	Notifications Notifications       `toml:"notifications"`
+	Metrics       Metrics             `toml:"metrics"`
+	Output        Output              `toml:"output"`
+	Log           Log                 `toml:"log"`
+	Stats         Stats               `toml:"stats"`
+	Modules       Modules             `toml:"-"` // decoded per kind (see decodeModules)
+	Profiles      map[string]Profile  `toml:"profiles"`
+	profileOrder  []string            // profile names in file order
+	moduleOrder   []ModuleRef         // order of module tables as they appeared in TOML
+	present       map[string]struct{} // module kinds explicitly present in the file
+	instances     map[ModuleRef]any   // named instance settings (value types, e.g. TimeModule)
+	SourcePath    string              `toml:"-"` // filesystem path the config was loaded from (empty if defaults only)
+	Sources       []string            `toml:"-"` // every file merged into the config: SourcePath, includes, host overlay
+	watches       []string            // files and include patterns to watch for reloads
 }
 
+// ModuleRef identifies one configured module instance.
+// Instance is empty for a kind's plain `[modules.<kind>]` table.
+type ModuleRef struct {
+	Kind     string
+	Instance string
+}
+
+// Modules holds the base settings of each module kind. Named instances
+// (`[modules.<kind>.<name>]` or `[[modules.<kind>]]`) start from these values.
 type Modules struct {
-	Time TimeModule   `toml:"time"`
-	CPU  CPUModule    `toml:"cpu"`
-	Mem  MemoryModule `toml:"mem"`
+	Time     TimeModule     `toml:"time"`
+	CPU      CPUModule      `toml:"cpu"`
+	Mem      MemoryModule   `toml:"mem"`
+	Disk     DiskModule     `toml:"disk"`
+	Calendar CalendarModule `toml:"calendar"`
+	Timer    TimerModule    `toml:"timer"`
+	Debug    DebugModule    `toml:"debug"`
+	Plugin   PluginModule   `toml:"plugin"`
+	Script   ScriptModule   `toml:"script"`
+	Exec     ExecModule     `toml:"exec"`
+}
+
+// Common holds settings shared by every module kind.
+type Common struct {
+	Enabled   bool   `toml:"enabled"`
+	Async     bool   `toml:"async"`                            // sample on a separate goroutine so a slow read cannot stall the bar
+	TimeoutMs int    `toml:"timeout_ms"`                       // async: a sample running longer marks the block stale (default 1000)
+	ShowWhen  string `toml:"show_when"`                        // expression; the block is hidden while it is false (see package expr)
+	Markup    string `toml:"markup" schema:"enum=|none|pango"` // "pango": render the text from template as Pango markup
+	Template  string `toml:"template"`                         // markup: placeholders {prefix} {value} {text} with helpers (default "{text}")
+	IconFont  string `toml:"icon_font"`                        // font family of the icon helper, e.g. "Symbols Nerd Font"
+}
+
+func (c *Common) common() *Common { return c }
+
+// Timeout is TimeoutMs as a duration, defaulting to one second.
+func (c Common) Timeout() time.Duration {
+	if c.TimeoutMs <= 0 {
+		return time.Second
+	}
+	return time.Duration(c.TimeoutMs) * time.Millisecond
 }
 
 type TimeModule struct {
-	Enabled bool   `toml:"enabled"`
-	Format  string `toml:"format"`
+	Common
+	Format   string     `toml:"format"`   // Go layout, or strftime pattern when it contains '%'
+	Timezone string     `toml:"timezone"` // IANA zone name, e.g. "Asia/Tokyo" (default local)
+	Locale   string     `toml:"locale"`   // month/day name language, e.g. "de_DE" (default English)
+	Cycle    []TimeView `toml:"cycle"`    // alternative views; left click cycles forward, right click back
+}
+
+// TimeView is an alternative rendering of a time block. Empty fields inherit
+// the module's format/timezone.
+type TimeView struct {
+	Format   string `toml:"format"`
+	Timezone string `toml:"timezone"`
+}
+
+// Thresholds configures severity for percentage-based modules. A level is
+// entered once the value has stayed at or above it for the *_for_sec duration
+// and left only when the value falls below the matching *_clear level, so a
+// value hovering around a threshold does not flicker.
+type Thresholds struct {
+	WarnPercent        int `toml:"warn_percent" schema:"min=1,max=100"`         // warn threshold
+	DangerPercent      int `toml:"danger_percent" schema:"min=1,max=100"`       // danger threshold
+	WarnClearPercent   int `toml:"warn_clear_percent" schema:"min=0,max=100"`   // leave warn below this (0 = warn_percent - 5, the default)
+	DangerClearPercent int `toml:"danger_clear_percent" schema:"min=0,max=100"` // leave danger below this (0 = danger_percent - 5, the default)
+	WarnForSec         int `toml:"warn_for_sec" schema:"min=0"`                 // seconds above warn before warning (default 0)
+	DangerForSec       int `toml:"danger_for_sec" schema:"min=0"`               // seconds above danger before danger (default 0)
+
+	Notify        string `toml:"notify" schema:"enum=off|warn|danger"` // desktop notification on entering: off, warn, danger (default off)
+	NotifySummary string `toml:"notify_summary"`                       // template; placeholders {module} {instance} {level} {value}
+	NotifyBody    string `toml:"notify_body"`                          // template, same placeholders
+}
+
+func (t *Thresholds) thresholds() *Thresholds { return t }
+
+// Notifications holds global desktop notification settings.
+type Notifications struct {
+	AppName        string `toml:"app_name"`                        // application name sent to the server (default "swaystats")
+	MinIntervalSec int    `toml:"min_interval_sec" schema:"min=0"` // per-block minimum seconds between notifications (default 60)
+	TimeoutMs      int    `toml:"timeout_ms" schema:"min=-1"`      // bubble timeout; -1 server default, 0 never expires (default -1)
+}
+
+// History configures an inline graph of recent samples for numeric modules.
+type History struct {
+	Graph      string `toml:"graph" schema:"enum=none|sparkline|braille"` // one of: none, sparkline, braille (default none)
+	GraphWidth int    `toml:"graph_width" schema:"min=1,max=120"`         // graph width in characters (default 10; braille shows 2 samples per character)
 }
 
 type CPUModule struct {
-	Enabled       bool   `toml:"enabled"`
-	IntervalSec   int    `toml:"interval_sec"`   // sampling interval seconds (default 2)
-	WarnPercent   int    `toml:"warn_percent"`   // warn threshold (default 70)
-	DangerPercent int    `toml:"danger_percent"` // danger threshold (default 90)
-	Precision     int    `toml:"precision"`      // decimals (0 or 1)
-	Prefix        string `toml:"prefix"`         // text/icon prefix before percentage (default "CPU")
+	Common
+	Thresholds // warn 70 / danger 90 by default
+	History
+	IntervalSec int    `toml:"interval_sec" schema:"min=1"`    // sampling interval seconds (default 2)
+	Precision   int    `toml:"precision" schema:"min=0,max=1"` // decimals (0 or 1)
+	Prefix      string `toml:"prefix"`                         // text/icon prefix before percentage (default "CPU")
 }
 
 type MemoryModule struct {
-	Enabled       bool   `toml:"enabled"`
-	IntervalSec   int    `toml:"interval_sec"`   // sampling interval seconds (default 5)
-	WarnPercent   int    `toml:"warn_percent"`   // warn threshold (default 70)
-	DangerPercent int    `toml:"danger_percent"` // danger threshold (default 90)
-	Precision     int    `toml:"precision"`      // percent decimals (0 or 1) for percent format
-	Prefix        string `toml:"prefix"`         // text/icon prefix (default "MEM")
-	Format        string `toml:"format"`         // one of: percent, available, used
+	Common
+	Thresholds // warn 70 / danger 90 by default
+	History
+	IntervalSec int    `toml:"interval_sec" schema:"min=1"`                 // sampling interval seconds (default 5)
+	Precision   int    `toml:"precision" schema:"min=0,max=1"`              // percent decimals (0 or 1) for percent format
+	Prefix      string `toml:"prefix"`                                      // text/icon prefix (default "MEM")
+	Format      string `toml:"format" schema:"enum=percent|available|used"` // one of: percent, available, used
+}
+
+type DiskModule struct {
+	Common
+	Thresholds // warn 80 / danger 90 by default
+	History
+	Path        string `toml:"path"`                                        // any path on the filesystem to report (default "/")
+	IntervalSec int    `toml:"interval_sec" schema:"min=1"`                 // sampling interval seconds (default 30)
+	Precision   int    `toml:"precision" schema:"min=0,max=1"`              // percent decimals (0 or 1) for percent format
+	Prefix      string `toml:"prefix"`                                      // text/icon prefix (default "DISK")
+	Format      string `toml:"format" schema:"enum=percent|available|used"` // one of: percent, available, used
+}
+
+type CalendarModule struct {
+	Common
+	Paths          []string `toml:"paths"`                          // .ics files or vdir directories (searched recursively)
+	IntervalSec    int      `toml:"interval_sec" schema:"min=1"`    // how often to check files for changes (default 60)
+	LookaheadHours int      `toml:"lookahead_hours" schema:"min=1"` // ignore events further ahead (default 24)
+	WarnMinutes    int      `toml:"warn_minutes" schema:"min=0"`    // warn color when the next event starts within (default 15)
+	DangerMinutes  int      `toml:"danger_minutes" schema:"min=0"`  // danger color when the next event starts within (default 5)
+	UrgentMinutes  int      `toml:"urgent_minutes" schema:"min=0"`  // mark urgent for this long after an event starts (default 5)
+	IncludeAllDay  bool     `toml:"include_all_day"`                // show all-day events (default false)
+	MaxLength      int      `toml:"max_length" schema:"min=1"`      // truncate event summaries to this many runes (default 30)
+	Prefix         string   `toml:"prefix"`                         // text/icon prefix (default "CAL")
+	EmptyText      string   `toml:"empty_text"`                     // shown when nothing is upcoming (default "no events")
+	OnClick        string   `toml:"on_click"`                       // shell command run on left click, e.g. "foot -e ikhal"
+}
+
+type TimerModule struct {
+	Common
+	Mode           string `toml:"mode" schema:"enum=pomodoro|countdown|stopwatch"` // one of: pomodoro, countdown, stopwatch
+	WorkMin        int    `toml:"work_min" schema:"min=1,max=1440"`                // pomodoro work phase minutes (default 25)
+	ShortBreakMin  int    `toml:"short_break_min" schema:"min=1,max=1440"`         // pomodoro short break minutes (default 5)
+	LongBreakMin   int    `toml:"long_break_min" schema:"min=1,max=1440"`          // pomodoro long break minutes (default 15)
+	LongBreakEvery int    `toml:"long_break_every" schema:"min=1,max=100"`         // work phases before a long break (default 4)
+	CountdownMin   int    `toml:"countdown_min" schema:"min=1,max=1440"`           // countdown minutes (default 10)
+	StepMin        int    `toml:"step_min" schema:"min=1,max=60"`                  // minutes added/removed per scroll step (default 1)
+	AutoAdvance    bool   `toml:"auto_advance"`                                    // start the next pomodoro phase without a click
+	Prefix         string `toml:"prefix"`                                          // text/icon prefix (default "TMR")
+	OnPhaseEnd     string `toml:"on_phase_end"`                                    // shell command run when a phase ends ($SWAYSTATS_TIMER_PHASE is set)
+}
+
+// DebugModule shows swaystats' own refresh statistics.
+type DebugModule struct {
+	Common
+	IntervalSec int    `toml:"interval_sec" schema:"min=1,max=3600"` // refresh interval (default 5)
+	Prefix      string `toml:"prefix"`                               // text/icon prefix (default "DBG")
+}
+
+// PluginModule runs an external block plugin (see package plugin). The whole
+// table, including keys swaystats does not know, is sent to the plugin.
+type PluginModule struct {
+	Common
+	Command       string         `toml:"command"`                                 // shell command starting the plugin
+	MaxBackoffSec int            `toml:"max_backoff_sec" schema:"min=1,max=3600"` // longest restart delay after crashes (default 60)
+	Table         map[string]any `toml:"-"`                                       // raw table passed in the init request
+}
+
+func (m *PluginModule) rawTable() *map[string]any { return &m.Table }
+
+// ScriptModule renders a block with an embedded Starlark script. As with
+// plugins, the whole table is visible to the script as `config`.
+type ScriptModule struct {
+	Common
+	Script      string         `toml:"script"`                               // path of the .star file (~ expanded)
+	IntervalSec int            `toml:"interval_sec" schema:"min=1,max=3600"` // refresh interval (default 5)
+	Table       map[string]any `toml:"-"`                                    // raw table exposed to the script
+}
+
+func (m *ScriptModule) rawTable() *map[string]any { return &m.Table }
+
+// ExecModule runs a shell command and shows its output, i3blocks style: the
+// first line is the full text, the second the short text, the third a color;
+// exit status 33 marks the block urgent. The command sees BLOCK_NAME,
+// BLOCK_INSTANCE and env; clicks re-run it with BLOCK_BUTTON, BLOCK_X and
+// BLOCK_Y set.
+type ExecModule struct {
+	Common
+	Command       string   `toml:"command"`                               // run via `sh -c`
+	IntervalSec   int      `toml:"interval_sec" schema:"min=0,max=86400"` // refresh interval; 0 runs only at startup and on clicks (default 5)
+	Prefix        string   `toml:"prefix"`                                // text/icon prefix (default none)
+	BlockInstance string   `toml:"block_instance"`                        // exported as BLOCK_INSTANCE (i3blocks' instance)
+	Env           []string `toml:"env"`                                   // extra environment, "KEY=value" entries
 }
 
 func Defaults() *Config {
-	return &Config{
-		TickHz: 1,
+	c := &Config{
+		TickHz:        1,
+		Notifications: Notifications{AppName: "swaystats", MinIntervalSec: 60, TimeoutMs: -1},
+		Output:        Output{Format: "i3bar", Separator: " | "},
+		Log:           Log{Level: "info", MaxSizeKB: 1024, Keep: 3},
+		Stats:         Stats{SlowMs: 50},
 		Modules: Modules{
-			Time: TimeModule{Enabled: true, Format: "2006-01-02 15:04:05"},
-			CPU:  CPUModule{Enabled: true, IntervalSec: 2, WarnPercent: 70, DangerPercent: 90, Precision: 0, Prefix: "CPU"},
-			Mem:  MemoryModule{Enabled: true, IntervalSec: 5, WarnPercent: 70, DangerPercent: 90, Precision: 0, Prefix: "MEM", Format: "percent"},
+			Time:     TimeModule{Common: Common{Enabled: true}, Format: "2006-01-02 15:04:05"},
+			CPU:      CPUModule{Common: Common{Enabled: true}, Thresholds: defaultThresholds(70, 90), History: defaultHistory, IntervalSec: 2, Precision: 0, Prefix: "CPU"},
+			Mem:      MemoryModule{Common: Common{Enabled: true}, Thresholds: defaultThresholds(70, 90), History: defaultHistory, IntervalSec: 5, Precision: 0, Prefix: "MEM", Format: "percent"},
+			Disk:     DiskModule{Common: Common{Enabled: true, Async: true}, Thresholds: defaultThresholds(80, 90), History: defaultHistory, Path: "/", IntervalSec: 30, Precision: 0, Prefix: "DISK", Format: "percent"},
+			Timer:    TimerModule{Common: Common{Enabled: true}, Mode: "pomodoro", WorkMin: 25, ShortBreakMin: 5, LongBreakMin: 15, LongBreakEvery: 4, CountdownMin: 10, StepMin: 1, Prefix: "TMR"},
+			Plugin:   PluginModule{Common: Common{Enabled: true}, MaxBackoffSec: 60},
+			Script:   ScriptModule{Common: Common{Enabled: true}, IntervalSec: 5},
+			Exec:     ExecModule{Common: Common{Enabled: true, Async: true}, IntervalSec: 5},
+			Debug:    DebugModule{Common: Common{Enabled: true}, IntervalSec: 5, Prefix: "DBG"},
+			Calendar: CalendarModule{Common: Common{Enabled: true}, IntervalSec: 60, LookaheadHours: 24, WarnMinutes: 15, DangerMinutes: 5, UrgentMinutes: 5, MaxLength: 30, Prefix: "CAL", EmptyText: "no events"},
 		},
-		// Default order (when no config file): cpu, mem, time (time last on the right end of bar cluster)
-		moduleOrder: []string{"cpu", "mem", "time"},
+		// Default order (when no config file): cpu, mem, time (time last on the right end of bar cluster).
+		// Disk, calendar, timer, debug, plugins, scripts and exec blocks are opt-in: they only render when a config file declares them.
+		moduleOrder: []ModuleRef{{Kind: "cpu"}, {Kind: "mem"}, {Kind: "time"}},
 	}
+	return c
+}
+
+// defaultThresholds returns the threshold defaults of a percentage module.
+// The clear levels stay 0 so normalize derives them from the thresholds a
+// config file ends up with.
+func defaultThresholds(warn, danger int) Thresholds {
+	return Thresholds{WarnPercent: warn, DangerPercent: danger, Notify: "off",
+		NotifySummary: "{module} {level}", NotifyBody: "{module} {instance} at {value}"}
 }
 
+var defaultHistory = History{Graph: "none", GraphWidth: 10}
+
 // Load loads configuration from explicit path or discovered search path.
 // Precedence: provided path (if exists) else first existing search path else defaults.
 // Missing file yields defaults and an error; parse errors also return defaults + error.
 func Load(path string) (*Config, error) {
 	defaults := Defaults()
+	defaults.normalize() // derive the clear levels
 	var chosen string
 	if path != "" {
 		chosen = path
@@ -79,55 +278,212 @@ func Load(path string) (*Config, error) {
 	if chosen == "" { // no file found
 		return defaults, errors.New("no config file found; using defaults")
 	}
-	data, err := os.ReadFile(chosen)
+	l, err := readLayers(chosen)
 	if err != nil {
-		return defaults, fmt.Errorf("read config: %w", err)
+		return defaults, err
 	}
-	md, err := toml.Decode(string(data), defaults) // decode overlays onto defaults
+	data, err := l.encode()
 	if err != nil {
+		return defaults, err
+	}
+	cfg := Defaults()
+	if _, err := toml.Decode(data, cfg); err != nil { // decode overlays onto defaults
+		return defaults, fmt.Errorf("parse config: %w", err)
+	}
+	var raw struct {
+		Modules map[string]toml.Primitive `toml:"modules"`
+	}
+	md, err := toml.Decode(data, &raw)
+	if err != nil {
+		return defaults, fmt.Errorf("parse config: %w", err)
+	}
+	// Order comes from the layers as written; the merged encoding sorts keys.
+	if err := cfg.decodeModules(md, l.keys, raw.Modules); err != nil {
 		return defaults, fmt.Errorf("parse config: %w", err)
 	}
+	cfg.profileOrder = tableOrder(md, l.keys, "profiles")
+	cfg.SourcePath = chosen
+	cfg.Sources, cfg.watches = l.files, l.watches
+	cfg.normalize()
+	return cfg, nil
+}
+
+// decodeModules overlays every `modules.*` table onto the defaults and records
+// module order from metadata keys. Three table shapes are understood:
+//
+//	[modules.cpu]        base instance of a kind
+//	[modules.time.utc]   named instance "utc" (inherits [modules.time] settings)
+//	[[modules.disk]]     array instances, named by their `instance` key or 1-based position
+//	[modules.vpn]        with `type = "script"`: instance "vpn" of the named kind
+//
+// When a kind has named or array instances, its base table only supplies shared
+// settings and is not rendered on its own.
+func (c *Config) decodeModules(md toml.MetaData, keys []toml.Key, tables map[string]toml.Primitive) error {
 	// If a config file exists, restart moduleOrder so file order fully controls ordering.
-	defaults.moduleOrder = nil
-	// Capture module order + presence from metadata keys: modules.<name>
-	seen := map[string]struct{}{}
-	present := map[string]struct{}{}
-	for _, k := range md.Keys() {
-		if len(k) == 2 && k[0] == "modules" {
-			name := k[1]
-			present[name] = struct{}{}
-			if _, ok := seen[name]; !ok {
-				defaults.moduleOrder = append(defaults.moduleOrder, name)
-				seen[name] = struct{}{}
+	c.moduleOrder = nil
+	c.present = map[string]struct{}{}
+	c.instances = map[ModuleRef]any{}
+
+	// Pass 1: base tables, so instances declared before their base still inherit it.
+	typed := map[string]string{} // table name -> kind, for tables with a `type` key
+	for name, p := range tables {
+		mk, ok := kinds[name]
+		if !ok {
+			var t struct {
+				Type string `toml:"type"`
+			}
+			if md.Type("modules", name) == "Hash" && md.PrimitiveDecode(p, &t) == nil {
+				if _, known := kinds[t.Type]; known {
+					typed[name] = t.Type
+				}
+			}
+			continue // otherwise an unknown module kind; ignored
+		}
+		c.present[name] = struct{}{}
+		if md.Type("modules", name) != "Hash" {
+			continue
+		}
+		if err := mk.decodeBase(md, p, &c.Modules); err != nil {
+			return fmt.Errorf("modules.%s: %w", name, err)
+		}
+	}
+
+	// Pass 2: walk keys in file order, decoding instances as they appear.
+	hasInstances := map[string]bool{}
+	arrayPos := map[string]int{}
+	seen := map[ModuleRef]struct{}{}
+	add := func(ref ModuleRef) {
+		if _, dup := seen[ref]; dup {
+			return
+		}
+		seen[ref] = struct{}{}
+		c.moduleOrder = append(c.moduleOrder, ref)
+	}
+	for _, k := range keys {
+		if len(k) < 2 || len(k) > 3 || k[0] != "modules" {
+			continue
+		}
+		name := k[1]
+		mk, ok := kinds[name]
+		if !ok {
+			if kind, typed := typed[name]; typed && len(k) == 2 {
+				v, err := kinds[kind].decodeInstance(md, tables[name], &c.Modules)
+				if err != nil {
+					return fmt.Errorf("modules.%s: %w", name, err)
+				}
+				ref := ModuleRef{Kind: kind, Instance: name}
+				c.instances[ref] = v
+				hasInstances[kind] = true
+				add(ref)
+			}
+			continue
+		}
+		switch {
+		case len(k) == 2 && md.Type(k...) == "Hash":
+			add(ModuleRef{Kind: name})
+		case len(k) == 2 && md.Type(k...) == "ArrayHash":
+			var elems []toml.Primitive
+			if err := md.PrimitiveDecode(tables[name], &elems); err != nil {
+				return fmt.Errorf("modules.%s: %w", name, err)
+			}
+			pos := arrayPos[name]
+			arrayPos[name]++
+			if pos >= len(elems) {
+				continue
+			}
+			var id struct {
+				Instance string `toml:"instance"`
+			}
+			if err := md.PrimitiveDecode(elems[pos], &id); err != nil {
+				return fmt.Errorf("modules.%s[%d]: %w", name, pos, err)
+			}
+			if id.Instance == "" {
+				id.Instance = strconv.Itoa(pos + 1)
+			}
+			v, err := mk.decodeInstance(md, elems[pos], &c.Modules)
+			if err != nil {
+				return fmt.Errorf("modules.%s[%d]: %w", name, pos, err)
+			}
+			ref := ModuleRef{Kind: name, Instance: id.Instance}
+			c.instances[ref] = v
+			hasInstances[name] = true
+			add(ref)
+		case len(k) == 3 && md.Type(k...) == "Hash":
+			var sub map[string]toml.Primitive
+			if err := md.PrimitiveDecode(tables[name], &sub); err != nil {
+				return fmt.Errorf("modules.%s: %w", name, err)
+			}
+			v, err := mk.decodeInstance(md, sub[k[2]], &c.Modules)
+			if err != nil {
+				return fmt.Errorf("modules.%s.%s: %w", name, k[2], err)
 			}
+			ref := ModuleRef{Kind: name, Instance: k[2]}
+			c.instances[ref] = v
+			hasInstances[name] = true
+			add(ref)
 		}
 	}
-	defaults.present = present
-	defaults.SourcePath = chosen
+
+	// Base tables of kinds with instances are templates only.
+	if len(hasInstances) > 0 {
+		order := c.moduleOrder[:0]
+		for _, ref := range c.moduleOrder {
+			if ref.Instance == "" && hasInstances[ref.Kind] {
+				continue
+			}
+			order = append(order, ref)
+		}
+		c.moduleOrder = order
+	}
 
 	// Implicit disable: if a module section is omitted in a user file, treat it as disabled.
-	// (Do not do this when no config file: earlier return already handled.)
-	// Only disable if not explicitly present.
-	if _, ok := present["time"]; !ok {
-		defaults.Modules.Time.Enabled = false
+	// (Do not do this when no config file: Load returns defaults before decoding.)
+	for name, mk := range kinds {
+		if _, ok := c.present[name]; !ok {
+			mk.disable(&c.Modules)
+		}
 	}
-	if _, ok := present["cpu"]; !ok {
-		defaults.Modules.CPU.Enabled = false
+	return nil
+}
+
+// StateDir returns the directory for persistent runtime state:
+// $XDG_STATE_HOME/swaystats, falling back to ~/.local/state/swaystats.
+func StateDir() string {
+	if xdg := os.Getenv("XDG_STATE_HOME"); xdg != "" {
+		return filepath.Join(xdg, "swaystats")
+	}
+	if home, _ := os.UserHomeDir(); home != "" {
+		return filepath.Join(home, ".local", "state", "swaystats")
 	}
-	if _, ok := present["mem"]; !ok {
-		defaults.Modules.Mem.Enabled = false
+	return ""
+}
+
+// Candidates returns the files Load(path) would consider: path itself if
+// set, else the search path.
+func Candidates(path string) []string {
+	if path != "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			return []string{abs}
+		}
+		return []string{path}
 	}
-	defaults.normalize()
-	return defaults, nil
+	return searchPaths()
 }
 
+// searchPaths lists config.toml, config.yaml, config.yml and config.json in
+// each config directory, in order of preference.
 func searchPaths() []string {
-	var out []string
+	var dirs, out []string
 	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
-		out = append(out, filepath.Join(xdg, "swaystats", "config.toml"))
+		dirs = append(dirs, filepath.Join(xdg, "swaystats"))
 	}
 	if home, _ := os.UserHomeDir(); home != "" {
-		out = append(out, filepath.Join(home, ".config", "swaystats", "config.toml"))
+		dirs = append(dirs, filepath.Join(home, ".config", "swaystats"))
+	}
+	for _, dir := range dirs {
+		for _, name := range []string{"config.toml", "config.yaml", "config.yml", "config.json"} {
+			out = append(out, filepath.Join(dir, name))
+		}
 	}
 	return out
 }
@@ -135,41 +491,302 @@ func searchPaths() []string {
 // normalize clamps and validates config values after decoding.
 func (c *Config) normalize() {
 	c.normalizeTick()
-	c.normalizeCPU()
-	c.normalizeMem()
+	c.Notifications.normalize()
+	if c.Log.Level == "" {
+		c.Log.Level = "info"
+	}
+	c.Log.MaxSizeKB = max(c.Log.MaxSizeKB, 0)
+	c.Log.Keep = max(c.Log.Keep, 0)
+	c.Stats.SlowMs = max(c.Stats.SlowMs, 0)
+	if c.Output.Format == "" {
+		c.Output.Format = "i3bar"
+	}
+	for _, mk := range kinds {
+		mk.normalize(&c.Modules)
+	}
+	for ref, v := range c.instances {
+		c.instances[ref] = kinds[ref.Kind].normalizeInstance(v)
+	}
 }
 
 // ModuleOrder returns a copy of the module order slice (may be empty).
-func (c *Config) ModuleOrder() []string {
+func (c *Config) ModuleOrder() []ModuleRef {
 	if len(c.moduleOrder) == 0 {
 		return nil
 	}
-	out := make([]string, len(c.moduleOrder))
+	out := make([]ModuleRef, len(c.moduleOrder))
 	copy(out, c.moduleOrder)
 	return out
 }
 
+// TimeFor returns the settings for a time instance ("" selects the base table).
+func (c *Config) TimeFor(instance string) TimeModule {
+	return instanceFor(c, "time", instance, c.Modules.Time)
+}
+
+// CPUFor returns the settings for a cpu instance ("" selects the base table).
+func (c *Config) CPUFor(instance string) CPUModule {
+	return instanceFor(c, "cpu", instance, c.Modules.CPU)
+}
+
+// MemFor returns the settings for a mem instance ("" selects the base table).
+func (c *Config) MemFor(instance string) MemoryModule {
+	return instanceFor(c, "mem", instance, c.Modules.Mem)
+}
+
+// CalendarFor returns the settings for a calendar instance ("" selects the base table).
+func (c *Config) CalendarFor(instance string) CalendarModule {
+	return instanceFor(c, "calendar", instance, c.Modules.Calendar)
+}
+
+// TimerFor returns the settings for a timer instance ("" selects the base table).
+func (c *Config) TimerFor(instance string) TimerModule {
+	return instanceFor(c, "timer", instance, c.Modules.Timer)
+}
+
+// DebugFor returns the settings for a debug instance ("" selects the base table).
+func (c *Config) DebugFor(instance string) DebugModule {
+	return instanceFor(c, "debug", instance, c.Modules.Debug)
+}
+
+// PluginFor returns the settings for a plugin instance ("" selects the base table).
+func (c *Config) PluginFor(instance string) PluginModule {
+	return instanceFor(c, "plugin", instance, c.Modules.Plugin)
+}
+
+// ScriptFor returns the settings for a script instance ("" selects the base table).
+func (c *Config) ScriptFor(instance string) ScriptModule {
+	return instanceFor(c, "script", instance, c.Modules.Script)
+}
+
+// ExecFor returns the settings for an exec instance ("" selects the base table).
+func (c *Config) ExecFor(instance string) ExecModule {
+	return instanceFor(c, "exec", instance, c.Modules.Exec)
+}
+
+// DiskFor returns the settings for a disk instance ("" selects the base table).
+func (c *Config) DiskFor(instance string) DiskModule {
+	return instanceFor(c, "disk", instance, c.Modules.Disk)
+}
+
+// CommonFor returns the shared settings of a module instance.
+func (c *Config) CommonFor(ref ModuleRef) Common {
+	mk, ok := kinds[ref.Kind]
+	if !ok {
+		return Common{}
+	}
+	return mk.commonOf(c, ref)
+}
+
+// ThresholdsFor returns the threshold settings of a module instance, or false
+// if the module kind has no thresholds.
+func (c *Config) ThresholdsFor(ref ModuleRef) (Thresholds, bool) {
+	mk, ok := kinds[ref.Kind]
+	if !ok {
+		return Thresholds{}, false
+	}
+	return mk.thresholds(c, ref)
+}
+
+// instanceFor looks up a named instance, falling back to the base settings.
+func instanceFor[T any](c *Config, kind, instance string, base T) T {
+	if instance == "" {
+		return base
+	}
+	if v, ok := c.instances[ModuleRef{Kind: kind, Instance: instance}].(T); ok {
+		return v
+	}
+	return base
+}
+
 func (c *Config) normalizeTick() {
 	c.TickHz = clampInt(c.TickHz, 1, 20, 1)
 }
 
-func (c *Config) normalizeCPU() {
-	if c.Modules.CPU.IntervalSec <= 0 {
-		c.Modules.CPU.IntervalSec = 2
+// normalize validates thresholds: warn in 1..100 (fallback defWarn), danger
+// above warn, and clear levels at or below their threshold.
+func (t *Thresholds) normalize(defWarn, defDanger int) {
+	t.WarnPercent = clampInt(t.WarnPercent, 1, 100, defWarn)
+	if t.DangerPercent == 0 {
+		t.DangerPercent = defDanger
+	}
+	if t.DangerPercent <= t.WarnPercent {
+		t.DangerPercent = t.WarnPercent + 10
+	}
+	if t.DangerPercent > 100 {
+		t.DangerPercent = 100
+	}
+	if t.WarnClearPercent <= 0 || t.WarnClearPercent > t.WarnPercent {
+		t.WarnClearPercent = max(t.WarnPercent-5, 0)
+	}
+	if t.DangerClearPercent <= 0 || t.DangerClearPercent > t.DangerPercent {
+		t.DangerClearPercent = max(t.DangerPercent-5, 0)
+	}
+	t.WarnForSec = max(t.WarnForSec, 0)
+	t.DangerForSec = max(t.DangerForSec, 0)
+	switch t.Notify {
+	case "warn", "danger":
+	default:
+		t.Notify = "off"
+	}
+	if t.NotifySummary == "" {
+		t.NotifySummary = "{module} {level}"
+	}
+	if t.NotifyBody == "" {
+		t.NotifyBody = "{module} {instance} at {value}"
+	}
+}
+
+// Log configures diagnostics (stdout is reserved for the bar). The
+// --log-level and --log-file flags take precedence. Read at startup only.
+type Log struct {
+	Level     string `toml:"level" schema:"enum=debug|info|warn|error"` // debug, info, warn, error (default info)
+	File      string `toml:"file"`                                      // "" = stderr; "state" = $XDG_STATE_HOME/swaystats/log; else a path
+	MaxSizeKB int    `toml:"max_size_kb" schema:"min=0"`                // rotate once the file would exceed this (default 1024; 0 = never)
+	Keep      int    `toml:"keep" schema:"min=0"`                       // rotated files kept as file.1 … file.N (default 3)
+}
+
+// LogPath resolves File to a filesystem path ("" means stderr).
+func (l Log) LogPath() string {
+	if l.File == "state" {
+		if dir := StateDir(); dir != "" {
+			return filepath.Join(dir, "log")
+		}
+		return ""
+	}
+	return ExpandHome(l.File)
+}
+
+// Stats configures self-profiling.
+type Stats struct {
+	SlowMs int `toml:"slow_ms" schema:"min=0"` // a provider refresh taking this long is logged as slow (default 50; 0 = off)
+}
+
+// Output selects how rows are rendered.
+type Output struct {
+	Format    string `toml:"format" schema:"enum=i3bar|waybar|lemonbar|polybar|tmux|plain"` // i3bar, waybar, lemonbar (polybar), tmux, plain (default i3bar)
+	Separator string `toml:"separator"`                                                     // joins blocks for single-line formats (default " | ")
+}
+
+// Metrics configures the optional Prometheus exporter.
+type Metrics struct {
+	Listen string `toml:"listen"` // "127.0.0.1:9101" or "unix:/path/to.sock"; empty disables (default)
+}
+
+func (n *Notifications) normalize() {
+	if n.AppName == "" {
+		n.AppName = "swaystats"
+	}
+	if n.MinIntervalSec < 0 {
+		n.MinIntervalSec = 0
+	}
+	if n.TimeoutMs < -1 {
+		n.TimeoutMs = -1
+	}
+}
+
+func (h *History) normalize() {
+	switch h.Graph {
+	case "none", "sparkline", "braille":
+	default:
+		h.Graph = "none"
+	}
+	h.GraphWidth = clampInt(h.GraphWidth, 1, 120, 10)
+}
+
+func (m *CPUModule) normalize() {
+	m.Thresholds.normalize(70, 90)
+	m.History.normalize()
+	if m.IntervalSec <= 0 {
+		m.IntervalSec = 2
+	}
+	m.Precision = clampInt(m.Precision, 0, 1, 0)
+}
+
+func (m *MemoryModule) normalize() {
+	m.Thresholds.normalize(70, 90)
+	m.History.normalize()
+	if m.IntervalSec <= 0 {
+		m.IntervalSec = 5
+	}
+	m.Precision = clampInt(m.Precision, 0, 1, 0)
+	if !validUsageFormat(m.Format) {
+		m.Format = "percent"
+	}
+}
+
+func (m *DiskModule) normalize() {
+	m.Thresholds.normalize(80, 90)
+	m.History.normalize()
+	if m.Path == "" {
+		m.Path = "/"
+	}
+	if m.IntervalSec <= 0 {
+		m.IntervalSec = 30
+	}
+	m.Precision = clampInt(m.Precision, 0, 1, 0)
+	if !validUsageFormat(m.Format) {
+		m.Format = "percent"
+	}
+}
+
+func (m *CalendarModule) normalize() {
+	if m.IntervalSec <= 0 {
+		m.IntervalSec = 60
+	}
+	if m.LookaheadHours <= 0 {
+		m.LookaheadHours = 24
+	}
+	if m.DangerMinutes < 0 {
+		m.DangerMinutes = 0
+	}
+	if m.WarnMinutes < m.DangerMinutes {
+		m.WarnMinutes = m.DangerMinutes
+	}
+	if m.UrgentMinutes < 0 {
+		m.UrgentMinutes = 0
+	}
+	if m.MaxLength <= 0 {
+		m.MaxLength = 30
 	}
-	c.Modules.CPU.Precision = clampInt(c.Modules.CPU.Precision, 0, 1, 0)
 }
 
-func (c *Config) normalizeMem() {
-	if c.Modules.Mem.IntervalSec <= 0 {
-		c.Modules.Mem.IntervalSec = 5
+func (m *TimerModule) normalize() {
+	switch m.Mode {
+	case "pomodoro", "countdown", "stopwatch":
+	default:
+		m.Mode = "pomodoro"
 	}
-	c.Modules.Mem.Precision = clampInt(c.Modules.Mem.Precision, 0, 1, 0)
-	if c.Modules.Mem.Format == "" {
-		c.Modules.Mem.Format = "percent"
+	m.WorkMin = clampInt(m.WorkMin, 1, 24*60, 25)
+	m.ShortBreakMin = clampInt(m.ShortBreakMin, 1, 24*60, 5)
+	m.LongBreakMin = clampInt(m.LongBreakMin, 1, 24*60, 15)
+	m.LongBreakEvery = clampInt(m.LongBreakEvery, 1, 100, 4)
+	m.CountdownMin = clampInt(m.CountdownMin, 1, 24*60, 10)
+	m.StepMin = clampInt(m.StepMin, 1, 60, 1)
+}
+
+func (m *PluginModule) normalize() {
+	m.MaxBackoffSec = clampInt(m.MaxBackoffSec, 1, 3600, 60)
+}
+
+func (m *ScriptModule) normalize() {
+	m.IntervalSec = clampInt(m.IntervalSec, 1, 3600, 5)
+}
+
+func (m *ExecModule) normalize() {
+	m.IntervalSec = max(min(m.IntervalSec, 86400), 0)
+}
+
+func (m *DebugModule) normalize() {
+	m.IntervalSec = clampInt(m.IntervalSec, 1, 3600, 5)
+	if m.Prefix == "" {
+		m.Prefix = "DBG"
 	}
-	if !validMemFormat(c.Modules.Mem.Format) {
-		c.Modules.Mem.Format = "percent"
+}
+
+func (m *TimeModule) normalize() {
+	if m.Format == "" {
+		m.Format = "2006-01-02 15:04:05"
 	}
 }
 
@@ -186,10 +803,21 @@ func clampInt(val, min, max, fallback int) int {
 	return val
 }
 
-func validMemFormat(f string) bool {
+// validUsageFormat reports whether f is a known mem/disk display format.
+func validUsageFormat(f string) bool {
 	switch f {
 	case "percent", "available", "used":
 		return true
 	}
 	return false
 }
+
+// ExpandHome replaces a leading "~/" with the user's home directory.
+func ExpandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
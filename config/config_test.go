@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfig writes a config file into a temporary directory and returns its path.
+func writeConfig(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestThresholdClearLevels(t *testing.T) {
+	tests := []struct {
+		name                   string
+		body                   string
+		warnClear, dangerClear int
+	}{
+		{"defaults", "[modules.cpu]\n", 65, 85},
+		{"derived from thresholds", "[modules.cpu]\nwarn_percent = 90\ndanger_percent = 95\n", 85, 90},
+		{"explicit", "[modules.cpu]\nwarn_percent = 90\nwarn_clear_percent = 50\n", 50, 95},
+		{"above threshold", "[modules.cpu]\nwarn_percent = 60\nwarn_clear_percent = 70\n", 55, 85},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(writeConfig(t, "config.toml", tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			th := cfg.Modules.CPU.Thresholds
+			if th.WarnClearPercent != tt.warnClear || th.DangerClearPercent != tt.dangerClear {
+				t.Errorf("clear levels = %d/%d, want %d/%d", th.WarnClearPercent, th.DangerClearPercent, tt.warnClear, tt.dangerClear)
+			}
+		})
+	}
+}
+
+func TestThresholdClearLevelsInstances(t *testing.T) {
+	body := "[modules.disk]\nwarn_percent = 60\n\n[modules.disk.home]\npath = \"/home\"\nwarn_percent = 40\n"
+	cfg, err := Load(writeConfig(t, "config.toml", body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.DiskFor("home").Thresholds.WarnClearPercent; got != 35 {
+		t.Errorf("instance warn clear = %d, want 35", got)
+	}
+	if got := cfg.Modules.Disk.Thresholds.WarnClearPercent; got != 55 {
+		t.Errorf("base warn clear = %d, want 55", got)
+	}
+}
+
+func TestDefaultsWithoutFile(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), "missing.toml"))
+	if err == nil {
+		t.Fatal("want an error for a missing file")
+	}
+	if got := cfg.Modules.Mem.Thresholds.WarnClearPercent; got != 65 {
+		t.Errorf("warn clear = %d, want 65", got)
+	}
+}
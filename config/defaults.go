@@ -0,0 +1,43 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+)
+
+// WriteDefault writes every setting with its default value as commented-out
+// TOML, suitable as a starting config file. Uncommenting a module table
+// enables that module.
+func WriteDefault(w io.Writer) error {
+	d := Defaults()
+	var buf bytes.Buffer
+	buf.WriteString("swaystats default configuration\n\n")
+	if err := toml.NewEncoder(&buf).Encode(d); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		buf.WriteString("\n[modules." + name + "]\n")
+		if err := toml.NewEncoder(&buf).Encode(kinds[name].defaults(d)); err != nil {
+			return err
+		}
+	}
+	bw := bufio.NewWriter(w)
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		if line := sc.Text(); line == "" {
+			bw.WriteString("\n")
+		} else {
+			bw.WriteString("# " + line + "\n")
+		}
+	}
+	return bw.Flush()
+}
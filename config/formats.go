@@ -0,0 +1,599 @@
+package config
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config files may be TOML, YAML or JSON, chosen by extension. Every format
+// is read into the same document (maps, []map[string]any for arrays of
+// tables, int64/float64/bool/string/time values) plus its keys in file order,
+// as toml.MetaData.Keys reports them, so merging, module order and
+// normalization are identical. YAML and JSON nest tables, so instances of
+// different kinds cannot be interleaved there: order follows first mention.
+
+// Formats lists the supported config formats.
+var Formats = []string{"toml", "yaml", "json"}
+
+// formatOf returns the format of path by extension (TOML by default).
+func formatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".json":
+		return "json"
+	}
+	return "toml"
+}
+
+// decodeDoc parses data in format into a document and its keys in order.
+func decodeDoc(format string, data []byte) (map[string]any, []toml.Key, error) {
+	switch format {
+	case "toml":
+		var doc map[string]any
+		md, err := toml.Decode(string(data), &doc)
+		if err != nil {
+			return nil, nil, err
+		}
+		return doc, md.Keys(), nil
+	case "yaml":
+		var n yaml.Node
+		if err := yaml.Unmarshal(data, &n); err != nil {
+			return nil, nil, err
+		}
+		if len(n.Content) == 0 { // empty file
+			return map[string]any{}, nil, nil
+		}
+		var d docBuilder
+		v, err := d.yaml(nil, n.Content[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		doc, ok := v.(map[string]any)
+		if !ok {
+			return nil, nil, errors.New("top level must be a mapping")
+		}
+		return doc, d.keys, nil
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var d docBuilder
+		v, err := d.json(nil, dec)
+		if err != nil {
+			return nil, nil, err
+		}
+		doc, ok := v.(map[string]any)
+		if !ok {
+			return nil, nil, errors.New("top level must be an object")
+		}
+		return doc, d.keys, nil
+	}
+	return nil, nil, fmt.Errorf("unknown config format %q (want one of %s)", format, strings.Join(Formats, ", "))
+}
+
+// docBuilder collects key paths while converting YAML or JSON values.
+type docBuilder struct {
+	keys []toml.Key
+}
+
+func (d *docBuilder) key(path []string) {
+	d.keys = append(d.keys, slices.Clone(path))
+}
+
+// table converts the entries of one mapping; null values are dropped, as
+// TOML has no null.
+func table(names []string, vals []any) map[string]any {
+	out := make(map[string]any, len(names))
+	for i, k := range names {
+		if vals[i] != nil {
+			out[k] = vals[i]
+		}
+	}
+	return out
+}
+
+// isTables reports whether n is a non-empty YAML sequence of mappings.
+func isTables(n *yaml.Node) bool {
+	if n.Kind != yaml.SequenceNode || len(n.Content) == 0 {
+		return false
+	}
+	for _, c := range n.Content {
+		if c.Kind != yaml.MappingNode {
+			return false
+		}
+	}
+	return true
+}
+
+// array turns a list of tables into an array of tables.
+func array(elems []any) any {
+	if len(elems) == 0 {
+		return elems
+	}
+	tables := make([]map[string]any, len(elems))
+	for i, e := range elems {
+		t, ok := e.(map[string]any)
+		if !ok {
+			return elems
+		}
+		tables[i] = t
+	}
+	return tables
+}
+
+func (d *docBuilder) yaml(path []string, n *yaml.Node) (any, error) {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return d.yaml(path, n.Alias)
+	case yaml.MappingNode:
+		var names []string
+		var vals []any
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Tag == "!!merge" {
+				return nil, fmt.Errorf("line %d: merge keys are not supported", n.Content[i].Line)
+			}
+			k := n.Content[i].Value
+			p := append(slices.Clone(path), k)
+			if !isTables(n.Content[i+1]) {
+				d.key(p)
+			}
+			v, err := d.yaml(p, n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			names, vals = append(names, k), append(vals, v)
+		}
+		return table(names, vals), nil
+	case yaml.SequenceNode:
+		elems := make([]any, 0, len(n.Content))
+		for _, c := range n.Content {
+			if isTables(n) {
+				d.key(path) // once per element, like [[array.of.tables]]
+			}
+			v, err := d.yaml(path, c)
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				elems = append(elems, v)
+			}
+		}
+		return array(elems), nil
+	case yaml.ScalarNode:
+		var v any
+		if err := n.Decode(&v); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		switch v := v.(type) {
+		case int:
+			return int64(v), nil
+		case uint64:
+			return int64(v), nil
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("line %d: unsupported YAML node", n.Line)
+}
+
+func (d *docBuilder) json(path []string, dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '{':
+			var names []string
+			var vals []any
+			for dec.More() {
+				kt, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				k := kt.(string)
+				p := append(slices.Clone(path), k)
+				// Arrays register their own keys (once per table element).
+				mark := len(d.keys)
+				d.key(p)
+				v, err := d.json(p, dec)
+				if err != nil {
+					return nil, err
+				}
+				if _, arr := v.([]map[string]any); arr {
+					d.keys = slices.Delete(d.keys, mark, mark+1)
+				}
+				names, vals = append(names, k), append(vals, v)
+			}
+			if _, err := dec.Token(); err != nil { // }
+				return nil, err
+			}
+			return table(names, vals), nil
+		case '[':
+			var elems []any
+			for dec.More() {
+				mark := len(d.keys)
+				v, err := d.json(path, dec)
+				if err != nil {
+					return nil, err
+				}
+				if _, table := v.(map[string]any); table {
+					d.keys = slices.Insert(d.keys, mark, slices.Clone(toml.Key(path)))
+				}
+				if v != nil {
+					elems = append(elems, v)
+				}
+			}
+			if _, err := dec.Token(); err != nil { // ]
+				return nil, err
+			}
+			return array(elems), nil
+		}
+	case json.Number:
+		if i, err := tok.Int64(); err == nil {
+			return i, nil
+		}
+		return tok.Float64()
+	case string, bool, nil:
+		return tok, nil
+	}
+	return nil, fmt.Errorf("unexpected JSON token %v", tok)
+}
+
+// readDoc reads a config file of any format.
+func readDoc(path, format string) (map[string]any, []toml.Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read config: %w", err)
+	}
+	if format == "" {
+		format = formatOf(path)
+	}
+	doc, keys, err := decodeDoc(format, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return doc, keys, nil
+}
+
+// Convert writes the config file at path in format to (toml, yaml or json),
+// keeping key order, comments aside. from overrides the extension. Includes
+// are not followed and ${VAR} references are kept as written.
+func Convert(w io.Writer, path, from, to string) error {
+	doc, keys, err := readDoc(path, from)
+	if err != nil {
+		return err
+	}
+	o := newKeyOrder(keys)
+	switch to {
+	case "toml":
+		return writeTOML(w, doc, keys, o)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(yamlNode(nil, doc, o)); err != nil {
+			return err
+		}
+		return enc.Close()
+	case "json":
+		var buf bytes.Buffer
+		if err := writeJSON(&buf, nil, doc, o, ""); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+	return fmt.Errorf("unknown config format %q (want one of %s)", to, strings.Join(Formats, ", "))
+}
+
+// keyOrder ranks key paths by first appearance, explicit or implied.
+type keyOrder map[string]int
+
+func newKeyOrder(keys []toml.Key) keyOrder {
+	o := keyOrder{}
+	for i, k := range keys {
+		for n := 1; n <= len(k); n++ { // parents count as mentioned by their children
+			p := strings.Join(k[:n], "\x00")
+			if _, ok := o[p]; !ok {
+				o[p] = i
+			}
+		}
+	}
+	return o
+}
+
+// sorted returns the keys of table (at path) in file order; keys the order
+// does not know go last, alphabetically.
+func (o keyOrder) sorted(path []string, table map[string]any) []string {
+	prefix := strings.Join(path, "\x00")
+	if prefix != "" {
+		prefix += "\x00"
+	}
+	rank := func(k string) int {
+		if r, ok := o[prefix+k]; ok {
+			return r
+		}
+		return math.MaxInt
+	}
+	names := make([]string, 0, len(table))
+	for k := range table {
+		names = append(names, k)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra - rb
+		}
+		return strings.Compare(a, b)
+	})
+	return names
+}
+
+func yamlNode(path []string, v any, o keyOrder) *yaml.Node {
+	switch v := v.(type) {
+	case map[string]any:
+		n := &yaml.Node{Kind: yaml.MappingNode}
+		for _, k := range o.sorted(path, v) {
+			n.Content = append(n.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: k},
+				yamlNode(append(slices.Clone(path), k), v[k], o))
+		}
+		return n
+	case []map[string]any:
+		n := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, e := range v {
+			n.Content = append(n.Content, yamlNode(path, e, o))
+		}
+		return n
+	case []any:
+		n := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, e := range v {
+			n.Content = append(n.Content, yamlNode(path, e, o))
+		}
+		return n
+	}
+	var n yaml.Node
+	if err := n.Encode(v); err != nil {
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(v)}
+	}
+	return &n
+}
+
+func writeJSON(buf *bytes.Buffer, path []string, v any, o keyOrder, indent string) error {
+	inner := indent + "  "
+	switch v := v.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{\n")
+		for i, k := range o.sorted(path, v) {
+			if i > 0 {
+				buf.WriteString(",\n")
+			}
+			name, _ := json.Marshal(k)
+			buf.WriteString(inner)
+			buf.Write(name)
+			buf.WriteString(": ")
+			if err := writeJSON(buf, append(slices.Clone(path), k), v[k], o, inner); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\n" + indent + "}")
+		return nil
+	case []map[string]any:
+		elems := make([]any, len(v))
+		for i, e := range v {
+			elems[i] = e
+		}
+		return writeJSON(buf, path, elems, o, indent)
+	case []any:
+		if len(v) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteString("[\n")
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteString(",\n")
+			}
+			buf.WriteString(inner)
+			if err := writeJSON(buf, path, e, o, inner); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\n" + indent + "]")
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
+}
+
+// writeTOML writes doc with its tables in the order keys first mention them,
+// so instances interleaved in the source stay interleaved. Tables inside
+// arrays of tables are written inline.
+func writeTOML(w io.Writer, doc map[string]any, keys []toml.Key, o keyOrder) error {
+	seen := map[string][]int{} // path -> positions in keys, one per mention
+	for i, k := range keys {
+		p := strings.Join(k, "\x00")
+		seen[p] = append(seen[p], i)
+	}
+	type header struct {
+		path  []string
+		table map[string]any
+		array bool
+		rank  int
+	}
+	var headers []header
+	rank := func(path []string, n int) int {
+		if at := seen[strings.Join(path, "\x00")]; n < len(at) {
+			return at[n]
+		}
+		return math.MaxInt
+	}
+	var collect func(path []string, t map[string]any)
+	collect = func(path []string, t map[string]any) {
+		for _, k := range o.sorted(path, t) {
+			p := append(slices.Clone(path), k)
+			switch v := t[k].(type) {
+			case map[string]any:
+				if len(v) == 0 || hasValues(v) { // pure parents are implied by their children
+					headers = append(headers, header{p, v, false, rank(p, 0)})
+				}
+				collect(p, v)
+			case []map[string]any:
+				for i, e := range v {
+					headers = append(headers, header{p, e, true, rank(p, i)})
+				}
+			}
+		}
+	}
+	collect(nil, doc)
+	slices.SortStableFunc(headers, func(a, b header) int { return cmp.Compare(a.rank, b.rank) })
+
+	var buf bytes.Buffer
+	writeTOMLValues(&buf, nil, doc, o, false)
+	for _, h := range headers {
+		if h.array {
+			fmt.Fprintf(&buf, "\n[[%s]]\n", tomlPath(h.path))
+		} else {
+			fmt.Fprintf(&buf, "\n[%s]\n", tomlPath(h.path))
+		}
+		writeTOMLValues(&buf, h.path, h.table, o, h.array)
+	}
+	_, err := w.Write(bytes.TrimPrefix(buf.Bytes(), []byte("\n")))
+	return err
+}
+
+// hasValues reports whether t holds anything besides tables.
+func hasValues(t map[string]any) bool {
+	for _, v := range t {
+		switch v.(type) {
+		case map[string]any, []map[string]any:
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// writeTOMLValues writes the values of a table. Sub-tables get their own
+// headers unless inline is set (elements of arrays of tables).
+func writeTOMLValues(buf *bytes.Buffer, path []string, t map[string]any, o keyOrder, inline bool) {
+	for _, k := range o.sorted(path, t) {
+		switch t[k].(type) {
+		case map[string]any, []map[string]any:
+			if !inline {
+				continue
+			}
+		}
+		fmt.Fprintf(buf, "%s = %s\n", tomlKey(k), tomlValue(append(slices.Clone(path), k), t[k], o))
+	}
+}
+
+func tomlPath(path []string) string {
+	parts := make([]string, len(path))
+	for i, k := range path {
+		parts[i] = tomlKey(k)
+	}
+	return strings.Join(parts, ".")
+}
+
+func tomlKey(k string) string {
+	if k != "" && strings.IndexFunc(k, func(r rune) bool {
+		return !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-')
+	}) < 0 {
+		return k
+	}
+	return tomlString(k)
+}
+
+// tomlString quotes s as a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func tomlValue(path []string, v any, o keyOrder) string {
+	switch v := v.(type) {
+	case string:
+		return tomlString(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		switch {
+		case math.IsInf(v, 1):
+			return "inf"
+		case math.IsInf(v, -1):
+			return "-inf"
+		case math.IsNaN(v):
+			return "nan"
+		}
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case map[string]any:
+		parts := make([]string, 0, len(v))
+		for _, k := range o.sorted(path, v) {
+			parts = append(parts, tomlKey(k)+" = "+tomlValue(append(slices.Clone(path), k), v[k], o))
+		}
+		return "{ " + strings.Join(parts, ", ") + " }"
+	case []map[string]any:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = tomlValue(path, e, o)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case []any:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = tomlValue(path, e, o)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case fmt.Stringer: // toml.LocalDate, LocalTime, LocalDateTime
+		return v.String()
+	}
+	return tomlString(fmt.Sprint(v))
+}
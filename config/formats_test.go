@@ -0,0 +1,271 @@
+package config
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+// The same config in each format.
+var formatDocs = map[string]string{
+	"toml": `tick_hz = 2
+
+[modules.time]
+format = "%H:%M"
+
+[[modules.disk]]
+path = "/"
+warn_percent = 70
+
+[modules.cpu]
+warn_percent = 60
+enabled = true
+
+[[modules.disk]]
+path = "/home"
+`,
+	"yaml": `tick_hz: 2
+modules:
+  time:
+    format: "%H:%M"
+  disk:
+    - path: /
+      warn_percent: 70
+    - path: /home
+  cpu:
+    warn_percent: 60
+    enabled: true
+    precision: ~
+`,
+	"json": `{
+  "tick_hz": 2,
+  "modules": {
+    "time": {"format": "%H:%M"},
+    "disk": [
+      {"path": "/", "warn_percent": 70},
+      {"path": "/home"}
+    ],
+    "cpu": {"warn_percent": 60, "enabled": true, "precision": null}
+  }
+}
+`,
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := []struct{ path, want string }{
+		{"config.toml", "toml"},
+		{"config", "toml"},
+		{"config.yaml", "yaml"},
+		{"CONFIG.YML", "yaml"},
+		{"config.json", "json"},
+		{"dir.json/config.conf", "toml"},
+	}
+	for _, tt := range tests {
+		if got := formatOf(tt.path); got != tt.want {
+			t.Errorf("formatOf(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeDocFormatsAgree(t *testing.T) {
+	want := map[string]any{
+		"tick_hz": int64(2),
+		"modules": map[string]any{
+			"time": map[string]any{"format": "%H:%M"},
+			"disk": []map[string]any{
+				{"path": "/", "warn_percent": int64(70)},
+				{"path": "/home"},
+			},
+			"cpu": map[string]any{"warn_percent": int64(60), "enabled": true},
+		},
+	}
+	for _, format := range Formats {
+		doc, keys, err := decodeDoc(format, []byte(formatDocs[format]))
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !reflect.DeepEqual(doc, want) {
+			t.Errorf("%s: doc = %#v\nwant %#v", format, doc, want)
+		}
+		// One key per element of an array of tables, as TOML reports them.
+		if n := countKey(keys, "modules.disk"); n != 2 {
+			t.Errorf("%s: modules.disk mentioned %d times, want 2", format, n)
+		}
+	}
+}
+
+func countKey(keys []toml.Key, key string) int {
+	n := 0
+	for _, k := range keys {
+		if strings.Join(k, ".") == key {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLoadFormatsAgree(t *testing.T) {
+	var orders [][]ModuleRef
+	for _, format := range Formats {
+		cfg, err := Load(writeConfig(t, "config."+format, formatDocs[format]))
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if got := cfg.DiskFor("2").Path; got != "/home" {
+			t.Errorf("%s: second disk path = %q, want /home", format, got)
+		}
+		if got := cfg.Modules.CPU.Thresholds.WarnPercent; got != 60 {
+			t.Errorf("%s: cpu warn_percent = %v, want 60", format, got)
+		}
+		orders = append(orders, cfg.ModuleOrder())
+	}
+	// TOML interleaves cpu between the disks; YAML and JSON group by kind.
+	want := []ModuleRef{{Kind: "time"}, {Kind: "disk", Instance: "1"}, {Kind: "disk", Instance: "2"}, {Kind: "cpu"}}
+	for i, format := range Formats[1:] {
+		if !slices.Equal(orders[i+1], want) {
+			t.Errorf("%s: order = %v, want %v", format, orders[i+1], want)
+		}
+	}
+	if !slices.Equal(orders[0], []ModuleRef{want[0], want[1], want[3], want[2]}) {
+		t.Errorf("toml: order = %v", orders[0])
+	}
+}
+
+func TestDecodeDocErrors(t *testing.T) {
+	tests := []struct{ format, src, want string }{
+		{"yaml", "base: &b {a: 1}\nmodules:\n  cpu:\n    <<: *b\n", "merge keys"},
+		{"yaml", "- a\n- b\n", "top level"},
+		{"yaml", "a: [\n", ""},
+		{"json", "[1, 2]", "top level"},
+		{"json", `{"a": }`, ""},
+		{"json", `{"a": 1`, ""},
+		{"toml", "a = \n", ""},
+		{"ini", "a = 1", "unknown config format"},
+	}
+	for _, tt := range tests {
+		_, _, err := decodeDoc(tt.format, []byte(tt.src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s %q: err = %v, want %q", tt.format, tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestDecodeDocEmpty(t *testing.T) {
+	for _, format := range Formats {
+		src := ""
+		if format == "json" {
+			src = "{}"
+		}
+		doc, _, err := decodeDoc(format, []byte(src))
+		if err != nil || len(doc) != 0 {
+			t.Errorf("%s: doc = %v, err = %v; want empty", format, doc, err)
+		}
+	}
+}
+
+func TestYAMLAliases(t *testing.T) {
+	src := "paths: &p [/, /home]\nmodules:\n  disk:\n    path: /\n    extra: *p\n"
+	doc, _, err := decodeDoc("yaml", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := doc["modules"].(map[string]any)["disk"].(map[string]any)["extra"]
+	if !reflect.DeepEqual(got, []any{"/", "/home"}) {
+		t.Errorf("alias = %#v", got)
+	}
+}
+
+// TestConvertRoundTrip converts through every format and back, keeping the
+// document and, for TOML, the interleaved table order.
+func TestConvertRoundTrip(t *testing.T) {
+	src := writeConfig(t, "config.toml", formatDocs["toml"])
+	want, _, err := readDoc(src, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, via := range []string{"yaml", "json", "toml"} {
+		var mid, back bytes.Buffer
+		if err := Convert(&mid, src, "", via); err != nil {
+			t.Fatalf("to %s: %v", via, err)
+		}
+		if err := Convert(&back, writeConfig(t, "mid."+via, mid.String()), "", "toml"); err != nil {
+			t.Fatalf("from %s: %v", via, err)
+		}
+		got, _, err := decodeDoc("toml", back.Bytes())
+		if err != nil {
+			t.Fatalf("via %s: %v\n%s", via, err, back.String())
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("via %s: %#v\nwant %#v", via, got, want)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := Convert(&out, src, "", "toml"); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != formatDocs["toml"] {
+		t.Errorf("toml to toml:\n%s\nwant\n%s", out.String(), formatDocs["toml"])
+	}
+	if err := Convert(&out, src, "", "ini"); err == nil {
+		t.Error("converting to ini succeeded")
+	}
+}
+
+func TestConvertJSONOrder(t *testing.T) {
+	var out bytes.Buffer
+	if err := Convert(&out, writeConfig(t, "config.yaml", "b: 1\na:\n  z: true\n  y: []\nc: {}\n"), "", "json"); err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"b\": 1,\n  \"a\": {\n    \"z\": true,\n    \"y\": []\n  },\n  \"c\": {}\n}\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestTOMLValue(t *testing.T) {
+	tests := []struct {
+		v    any
+		want string
+	}{
+		{"plain", `"plain"`},
+		{"q\"b\\n\nt\t\x01", `"q\"b\\n\nt\t\u0001"`},
+		{true, "true"},
+		{int64(-3), "-3"},
+		{2.0, "2.0"},
+		{0.25, "0.25"},
+		{1e21, "1000000000000000000000.0"},
+		{math.Inf(-1), "-inf"},
+		{math.NaN(), "nan"},
+		{[]any{int64(1), "a"}, `[1, "a"]`},
+		{map[string]any{"b": int64(1), "a key": "x"}, `{ "a key" = "x", b = 1 }`},
+		{[]map[string]any{{"path": "/"}}, `[{ path = "/" }]`},
+	}
+	for _, tt := range tests {
+		if got := tomlValue(nil, tt.v, keyOrder{}); got != tt.want {
+			t.Errorf("tomlValue(%#v) = %s, want %s", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestTOMLKey(t *testing.T) {
+	tests := []struct{ k, want string }{
+		{"cpu", "cpu"},
+		{"warn_percent", "warn_percent"},
+		{"my-disk2", "my-disk2"},
+		{"", `""`},
+		{"a.b", `"a.b"`},
+		{"home dir", `"home dir"`},
+		{"ü", `"ü"`},
+	}
+	for _, tt := range tests {
+		if got := tomlKey(tt.k); got != tt.want {
+			t.Errorf("tomlKey(%q) = %s, want %s", tt.k, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"maps"
+
+	"github.com/BurntSushi/toml"
+)
+
+// moduleKind decodes and normalizes the tables of one module kind.
+type moduleKind interface {
+	decodeBase(md toml.MetaData, p toml.Primitive, m *Modules) error
+	decodeInstance(md toml.MetaData, p toml.Primitive, m *Modules) (any, error)
+	normalizeInstance(v any) any
+	normalize(m *Modules)
+	disable(m *Modules)
+	thresholds(c *Config, ref ModuleRef) (Thresholds, bool)
+	defaults(c *Config) any
+	commonOf(c *Config, ref ModuleRef) Common
+}
+
+// settings is satisfied by pointers to module setting structs.
+type settings[T any] interface {
+	*T
+	common() *Common
+	normalize()
+}
+
+// kind binds a module kind to its base settings field in Modules.
+type kind[T any, P settings[T]] struct {
+	base func(m *Modules) P
+}
+
+// kinds lists every module kind the config understands, keyed by table name.
+var kinds = map[string]moduleKind{
+	"time":     kind[TimeModule, *TimeModule]{base: func(m *Modules) *TimeModule { return &m.Time }},
+	"cpu":      kind[CPUModule, *CPUModule]{base: func(m *Modules) *CPUModule { return &m.CPU }},
+	"mem":      kind[MemoryModule, *MemoryModule]{base: func(m *Modules) *MemoryModule { return &m.Mem }},
+	"disk":     kind[DiskModule, *DiskModule]{base: func(m *Modules) *DiskModule { return &m.Disk }},
+	"timer":    kind[TimerModule, *TimerModule]{base: func(m *Modules) *TimerModule { return &m.Timer }},
+	"calendar": kind[CalendarModule, *CalendarModule]{base: func(m *Modules) *CalendarModule { return &m.Calendar }},
+	"debug":    kind[DebugModule, *DebugModule]{base: func(m *Modules) *DebugModule { return &m.Debug }},
+	"plugin":   kind[PluginModule, *PluginModule]{base: func(m *Modules) *PluginModule { return &m.Plugin }},
+	"script":   kind[ScriptModule, *ScriptModule]{base: func(m *Modules) *ScriptModule { return &m.Script }},
+	"exec":     kind[ExecModule, *ExecModule]{base: func(m *Modules) *ExecModule { return &m.Exec }},
+}
+
+// rawTable is implemented by settings that also keep their table as a
+// generic map (plugins pass it on verbatim).
+type rawTable interface {
+	rawTable() *map[string]any
+}
+
+func (k kind[T, P]) decodeBase(md toml.MetaData, p toml.Primitive, m *Modules) error {
+	if err := md.PrimitiveDecode(p, k.base(m)); err != nil {
+		return err
+	}
+	if err := decodeRaw(md, p, k.base(m)); err != nil {
+		return err
+	}
+	// Sub-tables of a base table are named instances, not settings.
+	if rt, ok := any(k.base(m)).(rawTable); ok {
+		maps.DeleteFunc(*rt.rawTable(), func(_ string, v any) bool {
+			_, table := v.(map[string]any)
+			return table
+		})
+	}
+	return nil
+}
+
+// decodeInstance overlays p onto a copy of the base settings.
+func (k kind[T, P]) decodeInstance(md toml.MetaData, p toml.Primitive, m *Modules) (any, error) {
+	v := *k.base(m)
+	if err := md.PrimitiveDecode(p, P(&v)); err != nil {
+		return nil, err
+	}
+	if err := decodeRaw(md, p, P(&v)); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// decodeRaw overlays p onto a copy of the raw table of s, if it keeps one.
+func decodeRaw(md toml.MetaData, p toml.Primitive, s any) error {
+	rt, ok := s.(rawTable)
+	if !ok {
+		return nil
+	}
+	raw := maps.Clone(*rt.rawTable())
+	if raw == nil {
+		raw = map[string]any{}
+	}
+	if err := md.PrimitiveDecode(p, &raw); err != nil {
+		return err
+	}
+	*rt.rawTable() = raw
+	return nil
+}
+
+func (k kind[T, P]) normalizeInstance(v any) any {
+	t, ok := v.(T)
+	if !ok {
+		return v
+	}
+	P(&t).normalize()
+	return t
+}
+
+func (k kind[T, P]) normalize(m *Modules) { k.base(m).normalize() }
+
+func (k kind[T, P]) disable(m *Modules) { k.base(m).common().Enabled = false }
+
+func (k kind[T, P]) thresholds(c *Config, ref ModuleRef) (Thresholds, bool) {
+	v := instanceFor(c, ref.Kind, ref.Instance, *k.base(&c.Modules))
+	if t, ok := any(P(&v)).(interface{ thresholds() *Thresholds }); ok {
+		return *t.thresholds(), true
+	}
+	return Thresholds{}, false
+}
+
+func (k kind[T, P]) defaults(c *Config) any { return *k.base(&c.Modules) }
+
+func (k kind[T, P]) commonOf(c *Config, ref ModuleRef) Common {
+	v := instanceFor(c, ref.Kind, ref.Instance, *k.base(&c.Modules))
+	return *P(&v).common()
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// A config is assembled from layers, later ones overriding earlier ones:
+//
+//	config.toml            the main file
+//	include = [...]        files matching its include patterns, in order (recursively)
+//	config.<host>.toml     the host overlay next to the main file, if present
+//
+// Each file may be TOML, YAML or JSON (see formats.go); the overlay shares the
+// main file's extension.
+//
+// Tables merge key by key, arrays of tables ([[modules.disk]]) are appended
+// and any other value is replaced. `${VAR}` (or `${VAR:-default}`) and a
+// leading `~/` are expanded in every string.
+
+// layers is the merged result of reading a main file and everything it pulls in.
+type layers struct {
+	doc     map[string]any // merged document
+	keys    []toml.Key     // keys of every layer, in reading order (drives module order)
+	files   []string       // files read
+	watches []string       // files and glob patterns whose changes require a reload
+}
+
+// readLayers reads path, its includes and its host overlay.
+func readLayers(path string) (*layers, error) {
+	l := &layers{doc: map[string]any{}}
+	seen := map[string]bool{}
+	if err := l.read(path, seen); err != nil {
+		return nil, err
+	}
+	if overlay := hostOverlay(path); overlay != "" {
+		l.watches = append(l.watches, overlay)
+		if _, err := os.Stat(overlay); err == nil {
+			if err := l.read(overlay, seen); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return l, nil
+}
+
+// read merges one file, then the files matched by its include patterns.
+func (l *layers) read(path string, seen map[string]bool) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if seen[abs] {
+		return nil // already merged (include cycle or overlapping globs)
+	}
+	seen[abs] = true
+	doc, keys, err := readDoc(path, "")
+	if err != nil {
+		return err
+	}
+	expandStrings(doc)
+	includes, err := includePatterns(doc["include"], filepath.Dir(abs))
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	delete(doc, "include")
+	mergeTables(l.doc, doc)
+	l.keys = append(l.keys, keys...)
+	l.files = append(l.files, abs)
+	l.watches = append(l.watches, abs)
+	for _, pattern := range includes {
+		l.watches = append(l.watches, pattern)
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: include %q: %w", path, pattern, err)
+		}
+		for _, m := range matches {
+			if err := l.read(m, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// encode renders the merged document as TOML for the regular decoder.
+func (l *layers) encode() (string, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(l.doc); err != nil {
+		return "", fmt.Errorf("merge config: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// includePatterns returns the include value (a string or array of strings)
+// as absolute glob patterns, relative ones resolved against dir.
+func includePatterns(v any, dir string) ([]string, error) {
+	var raw []any
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		raw = []any{v}
+	case []any:
+		raw = v
+	default:
+		return nil, fmt.Errorf("include: want string or array of strings, got %T", v)
+	}
+	patterns := make([]string, 0, len(raw))
+	for _, r := range raw {
+		p, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("include: want strings, got %T", r)
+		}
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// hostOverlay returns the host-specific overlay of path, e.g.
+// config.toml -> config.laptop.toml (short hostname), or "" without a hostname.
+func hostOverlay(path string) string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return ""
+	}
+	host, _, _ = strings.Cut(host, ".")
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	ext := filepath.Ext(abs)
+	return strings.TrimSuffix(abs, ext) + "." + host + ext
+}
+
+// mergeTables merges src into dst (see the package comment above).
+func mergeTables(dst, src map[string]any) {
+	for k, v := range src {
+		switch v := v.(type) {
+		case map[string]any:
+			if d, ok := dst[k].(map[string]any); ok {
+				mergeTables(d, v)
+				continue
+			}
+		case []map[string]any:
+			if d, ok := dst[k].([]map[string]any); ok {
+				dst[k] = append(d, v...)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+// envRef matches ${NAME} and ${NAME:-default}; $${...} escapes a literal ${...}.
+var envRef = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// expandString expands environment references and a leading ~/ (a lone "~",
+// e.g. a separator, is left alone).
+func expandString(s string) string {
+	if strings.Contains(s, "${") {
+		s = envRef.ReplaceAllStringFunc(s, func(ref string) string {
+			if strings.HasPrefix(ref, "$$") {
+				return ref[1:]
+			}
+			m := envRef.FindStringSubmatch(ref)
+			if v, ok := os.LookupEnv(m[1]); ok && v != "" {
+				return v
+			}
+			return m[2]
+		})
+	}
+	if strings.HasPrefix(s, "~/") {
+		return ExpandHome(s)
+	}
+	return s
+}
+
+// expandStrings expands every string in a decoded document in place.
+func expandStrings(v any) any {
+	switch v := v.(type) {
+	case string:
+		return expandString(v)
+	case map[string]any:
+		for k, e := range v {
+			v[k] = expandStrings(e)
+		}
+	case []map[string]any:
+		for _, e := range v {
+			expandStrings(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = expandStrings(e)
+		}
+	}
+	return v
+}
+
+// WatchPaths returns the files and include patterns c was loaded from,
+// including a host overlay that does not exist yet.
+func (c *Config) WatchPaths() []string {
+	return append([]string(nil), c.watches...)
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Profile replaces the module set and order while it is active. Profiles are
+// chosen manually (`swaystats profile <name>`) or by the first whose `when`
+// expression holds; see package profile.
+type Profile struct {
+	When    string   `toml:"when"`    // condition for automatic selection (empty: manual only)
+	Modules []string `toml:"modules"` // "kind" (with all its instances) or "kind.instance", in bar order
+}
+
+// ProfileNames returns the configured profile names in file order.
+func (c *Config) ProfileNames() []string {
+	return append([]string(nil), c.profileOrder...)
+}
+
+// WithProfile returns a copy of c whose module order is that of the named
+// profile. Modules the file does not configure are skipped; an unknown name,
+// or a profile without modules, returns c itself.
+func (c *Config) WithProfile(name string) *Config {
+	p, ok := c.Profiles[name]
+	if !ok || len(p.Modules) == 0 {
+		return c
+	}
+	out := *c
+	out.moduleOrder = nil
+	for _, m := range p.Modules {
+		kind, instance, _ := strings.Cut(m, ".")
+		if instance != "" {
+			out.moduleOrder = append(out.moduleOrder, ModuleRef{Kind: kind, Instance: instance})
+			continue
+		}
+		// A bare kind stands for all its instances as ordered in the file.
+		found := false
+		for _, ref := range c.moduleOrder {
+			if ref.Kind == kind {
+				out.moduleOrder = append(out.moduleOrder, ref)
+				found = true
+			}
+		}
+		if !found {
+			out.moduleOrder = append(out.moduleOrder, ModuleRef{Kind: kind})
+		}
+	}
+	return &out
+}
+
+// tableOrder returns the names of the sub-tables of table in the order keys
+// first mention them.
+func tableOrder(md toml.MetaData, keys []toml.Key, table string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, k := range keys {
+		if len(k) == 2 && k[0] == table && md.Type(k...) == "Hash" && !seen[k[1]] {
+			seen[k[1]] = true
+			names = append(names, k[1])
+		}
+	}
+	return names
+}
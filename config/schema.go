@@ -0,0 +1,328 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The JSON Schema of the config file is derived from the Go types: toml tags
+// name the fields, Defaults() supplies the defaults and `schema` tags carry
+// the constraints normalize enforces:
+//
+//	schema:"min=1,max=20"           integer range (either bound optional)
+//	schema:"enum=none|sparkline"    allowed strings
+//
+// WriteSchema refuses to emit a schema that disagrees with the defaults or
+// with normalize; schema_test.go runs the same check, and validates the
+// defaults and the example config against the schema.
+
+const schemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// constraint is a parsed `schema` tag.
+type constraint struct {
+	min, max *int
+	enum     []string
+}
+
+func parseConstraint(tag string) (constraint, error) {
+	var c constraint
+	for part := range strings.SplitSeq(tag, ",") {
+		if part == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "min", "max":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return c, fmt.Errorf("schema tag %q: %w", tag, err)
+			}
+			if k == "min" {
+				c.min = &n
+			} else {
+				c.max = &n
+			}
+		case "enum":
+			c.enum = strings.Split(v, "|")
+		default:
+			return c, fmt.Errorf("schema tag %q: unknown key %q", tag, k)
+		}
+	}
+	return c, nil
+}
+
+// check reports whether v satisfies c.
+func (c constraint) check(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Int:
+		n := int(v.Int())
+		if c.min != nil && n < *c.min || c.max != nil && n > *c.max {
+			return fmt.Errorf("%d outside %s", n, c.rangeText())
+		}
+	case reflect.String:
+		if c.enum != nil && !slices.Contains(c.enum, v.String()) {
+			return fmt.Errorf("%q not one of %s", v.String(), strings.Join(c.enum, ", "))
+		}
+	}
+	return nil
+}
+
+func (c constraint) rangeText() string {
+	lo, hi := "-inf", "inf"
+	if c.min != nil {
+		lo = strconv.Itoa(*c.min)
+	}
+	if c.max != nil {
+		hi = strconv.Itoa(*c.max)
+	}
+	return "[" + lo + ", " + hi + "]"
+}
+
+// WriteSchema writes the JSON Schema of the config file (usable by taplo /
+// Even Better TOML, or YAML and JSON language servers) as indented JSON.
+func WriteSchema(w io.Writer) error {
+	if err := checkSchema(); err != nil {
+		return fmt.Errorf("schema and defaults disagree: %w", err)
+	}
+	s, err := schema()
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+func schema() (map[string]any, error) {
+	d := Defaults()
+	props, err := objectProps(reflect.ValueOf(d).Elem())
+	if err != nil {
+		return nil, err
+	}
+	props["include"] = map[string]any{
+		"description": "files merged on top of this one (globs, relative to this file)",
+		"anyOf": []any{
+			map[string]any{"type": "string"},
+			map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		},
+	}
+
+	// A kind is a base table (whose sub-tables are named instances) or an
+	// array of instances; any other table with `type = "<kind>"` is an
+	// instance of that kind.
+	modules := map[string]any{}
+	defs := map[string]any{}
+	var typed []any
+	for _, name := range slices.Sorted(maps.Keys(kinds)) {
+		v := reflect.ValueOf(kinds[name].defaults(d))
+		fields, err := objectProps(v)
+		if err != nil {
+			return nil, fmt.Errorf("modules.%s: %w", name, err)
+		}
+		_, raw := reflect.New(v.Type()).Interface().(rawTable)
+		ref := map[string]any{"$ref": "#/definitions/" + name}
+		instance := map[string]any{
+			"instance": map[string]any{"type": "string", "description": "instance name ([[modules." + name + "]] elements)"},
+			"type":     map[string]any{"const": name},
+		}
+		for k, f := range fields {
+			instance[k] = f
+		}
+		defs[name] = map[string]any{"type": "object", "properties": instance, "additionalProperties": raw}
+		var named any = ref
+		if raw {
+			named = true // plugins and scripts take arbitrary keys, sub-tables included
+		}
+		modules[name] = map[string]any{"anyOf": []any{
+			map[string]any{"type": "object", "properties": fields, "additionalProperties": named},
+			map[string]any{"type": "array", "items": ref},
+		}}
+		typed = append(typed, map[string]any{
+			"allOf":    []any{ref},
+			"required": []any{"type"},
+		})
+	}
+	props["modules"] = map[string]any{
+		"type":                 "object",
+		"properties":           modules,
+		"additionalProperties": map[string]any{"anyOf": typed},
+	}
+	return map[string]any{
+		"$schema":              schemaDraft,
+		"title":                "swaystats config",
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+		"definitions":          defs,
+	}, nil
+}
+
+// objectProps returns the schemas of the toml fields of struct v, with
+// embedded structs flattened. v supplies the defaults.
+func objectProps(v reflect.Value) (map[string]any, error) {
+	props := map[string]any{}
+	t := v.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("toml"), ",")
+		if f.Anonymous && name == "" {
+			inner, err := objectProps(v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+			for k, s := range inner {
+				props[k] = s
+			}
+			continue
+		}
+		if name == "" || name == "-" {
+			continue
+		}
+		s, err := valueSchema(v.Field(i), f.Tag.Get("schema"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		props[name] = s
+	}
+	return props, nil
+}
+
+// valueSchema returns the schema of one value, with v as its default.
+func valueSchema(v reflect.Value, tag string) (map[string]any, error) {
+	c, err := parseConstraint(tag)
+	if err != nil {
+		return nil, err
+	}
+	s := map[string]any{}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int64:
+		s["type"] = "integer"
+		s["default"] = v.Int()
+		if c.min != nil {
+			s["minimum"] = *c.min
+		}
+		if c.max != nil {
+			s["maximum"] = *c.max
+		}
+	case reflect.Float64:
+		s["type"] = "number"
+		s["default"] = v.Float()
+	case reflect.Bool:
+		s["type"] = "boolean"
+		s["default"] = v.Bool()
+	case reflect.String:
+		s["type"] = "string"
+		s["default"] = v.String()
+		if c.enum != nil {
+			s["enum"] = c.enum
+		}
+	case reflect.Slice:
+		items, err := valueSchema(reflect.Zero(v.Type().Elem()), "")
+		if err != nil {
+			return nil, err
+		}
+		delete(items, "default")
+		s["type"] = "array"
+		s["items"] = items
+	case reflect.Map:
+		items, err := valueSchema(reflect.Zero(v.Type().Elem()), "")
+		if err != nil {
+			return nil, err
+		}
+		s["type"] = "object"
+		s["additionalProperties"] = items
+	case reflect.Struct:
+		props, err := objectProps(v)
+		if err != nil {
+			return nil, err
+		}
+		s["type"] = "object"
+		s["properties"] = props
+		s["additionalProperties"] = false
+	default:
+		return nil, fmt.Errorf("no schema for %s", v.Type())
+	}
+	return s, nil
+}
+
+// checkSchema verifies that every default satisfies its constraint and that
+// normalize brings out-of-range numbers back into range.
+func checkSchema() error {
+	var fields []constrained
+	if err := collectConstrained(reflect.TypeFor[Config](), nil, "", &fields); err != nil {
+		return err
+	}
+	d := reflect.ValueOf(Defaults()).Elem()
+	for _, f := range fields {
+		if err := f.c.check(d.FieldByIndex(f.index)); err != nil {
+			return fmt.Errorf("default %s: %w", f.path, err)
+		}
+		if d.FieldByIndex(f.index).Kind() != reflect.Int {
+			continue
+		}
+		var probes []int
+		if f.c.min != nil {
+			probes = append(probes, *f.c.min-1)
+		}
+		if f.c.max != nil {
+			probes = append(probes, *f.c.max+1)
+		}
+		for _, n := range probes {
+			cfg := Defaults()
+			v := reflect.ValueOf(cfg).Elem().FieldByIndex(f.index)
+			v.SetInt(int64(n))
+			cfg.normalize()
+			if err := f.c.check(v); err != nil {
+				return fmt.Errorf("normalize %s = %d: %w", f.path, n, err)
+			}
+		}
+	}
+	return nil
+}
+
+// constrained is a field with a `schema` tag, located by its index path.
+type constrained struct {
+	path  string
+	index []int
+	c     constraint
+}
+
+func collectConstrained(t reflect.Type, index []int, path string, out *[]constrained) error {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		idx := append(slices.Clone(index), i)
+		p := path
+		if name, _, _ := strings.Cut(f.Tag.Get("toml"), ","); !f.Anonymous {
+			if name == "" || name == "-" {
+				name = strings.ToLower(f.Name) // Modules
+			}
+			p = strings.TrimPrefix(path+"."+name, ".")
+		}
+		if f.Type.Kind() == reflect.Struct {
+			if err := collectConstrained(f.Type, idx, p, out); err != nil {
+				return err
+			}
+			continue
+		}
+		if tag, ok := f.Tag.Lookup("schema"); ok {
+			c, err := parseConstraint(tag)
+			if err != nil {
+				return fmt.Errorf("%s: %w", p, err)
+			}
+			*out = append(*out, constrained{p, idx, c})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+func TestCheckSchema(t *testing.T) {
+	if err := checkSchema(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestDefaultsMatchSchema validates the default config, as printed by
+// --print-default-config, against the generated schema.
+func TestDefaultsMatchSchema(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDefault(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var src strings.Builder
+	for i, line := range strings.Split(buf.String(), "\n") {
+		if i > 0 { // the first line is a title
+			src.WriteString(strings.TrimPrefix(line, "# ") + "\n")
+		}
+	}
+	validateTOML(t, src.String())
+}
+
+func TestExampleMatchesSchema(t *testing.T) {
+	data, err := os.ReadFile("../examples/config.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	validateTOML(t, string(data))
+}
+
+func TestSchemaRejects(t *testing.T) {
+	for _, src := range []string{
+		"tick_hz = 50\n",
+		"bogus = 1\n",
+		"[modules.cpu]\nprecision = 2\n",
+		"[modules.mem]\nformat = \"bytes\"\n",
+		"[modules.cpu]\nfoo = 1\n",
+		"[modules.vpn]\ntype = \"nope\"\n",
+		"[modules.time]\nmarkup = \"html\"\n",
+	} {
+		if err := validate(t, src); err == nil {
+			t.Errorf("%q: accepted, want an error", src)
+		}
+	}
+}
+
+func validateTOML(t *testing.T, src string) {
+	t.Helper()
+	if err := validate(t, src); err != nil {
+		t.Error(err)
+	}
+}
+
+// validate checks a TOML document against the schema, after converting both
+// to plain JSON values.
+func validate(t *testing.T, src string) error {
+	t.Helper()
+	var doc map[string]any
+	if _, err := toml.Decode(src, &doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	s, err := schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &validator{root: jsonValue(t, s).(map[string]any)}
+	return v.check("$", v.root, jsonValue(t, doc))
+}
+
+func jsonValue(t *testing.T, v any) any {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// validator implements the part of JSON Schema draft-07 that schema() uses.
+type validator struct {
+	root map[string]any
+}
+
+func (v *validator) check(path string, s any, val any) error {
+	switch s := s.(type) {
+	case bool:
+		if !s {
+			return fmt.Errorf("%s: not allowed", path)
+		}
+		return nil
+	case map[string]any:
+		return v.checkObject(path, s, val)
+	}
+	return fmt.Errorf("%s: bad schema %v", path, s)
+}
+
+func (v *validator) checkObject(path string, s map[string]any, val any) error {
+	if ref, ok := s["$ref"].(string); ok {
+		def := v.root["definitions"].(map[string]any)[strings.TrimPrefix(ref, "#/definitions/")]
+		if err := v.check(path, def, val); err != nil {
+			return err
+		}
+	}
+	if c, ok := s["const"]; ok && c != val {
+		return fmt.Errorf("%s: %v is not %v", path, val, c)
+	}
+	if enum, ok := s["enum"].([]any); ok && !slices.Contains(enum, val) {
+		return fmt.Errorf("%s: %v not in %v", path, val, enum)
+	}
+	if typ, ok := s["type"].(string); ok {
+		if err := checkType(path, typ, val); err != nil {
+			return err
+		}
+	}
+	if n, ok := val.(float64); ok {
+		if lo, ok := s["minimum"].(float64); ok && n < lo {
+			return fmt.Errorf("%s: %v below %v", path, n, lo)
+		}
+		if hi, ok := s["maximum"].(float64); ok && n > hi {
+			return fmt.Errorf("%s: %v above %v", path, n, hi)
+		}
+	}
+	if all, ok := s["allOf"].([]any); ok {
+		for _, sub := range all {
+			if err := v.check(path, sub, val); err != nil {
+				return err
+			}
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]any); ok {
+		var errs []string
+		for _, sub := range anyOf {
+			err := v.check(path, sub, val)
+			if err == nil {
+				errs = nil
+				break
+			}
+			errs = append(errs, err.Error())
+		}
+		if errs != nil {
+			return fmt.Errorf("%s: no alternative matches (%s)", path, strings.Join(errs, "; "))
+		}
+	}
+	if items, ok := s["items"]; ok {
+		if arr, ok := val.([]any); ok {
+			for i, el := range arr {
+				if err := v.check(fmt.Sprintf("%s[%d]", path, i), items, el); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	obj, ok := val.(map[string]any)
+	if !ok {
+		return nil
+	}
+	if req, ok := s["required"].([]any); ok {
+		for _, k := range req {
+			if _, ok := obj[k.(string)]; !ok {
+				return fmt.Errorf("%s: missing %s", path, k)
+			}
+		}
+	}
+	props, _ := s["properties"].(map[string]any)
+	for k, el := range obj {
+		sub, ok := props[k]
+		if !ok {
+			sub, ok = s["additionalProperties"]
+		}
+		if !ok {
+			continue
+		}
+		if err := v.check(path+"."+k, sub, el); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkType(path, typ string, val any) error {
+	ok := false
+	switch typ {
+	case "object":
+		_, ok = val.(map[string]any)
+	case "array":
+		_, ok = val.([]any)
+	case "string":
+		_, ok = val.(string)
+	case "boolean":
+		_, ok = val.(bool)
+	case "number":
+		_, ok = val.(float64)
+	case "integer":
+		n, isNum := val.(float64)
+		ok = isNum && n == math.Trunc(n)
+	}
+	if !ok {
+		return fmt.Errorf("%s: %v is not of type %s", path, val, typ)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Package events is the in-process bus on which providers publish state
+// changes (currently severity transitions) for consumers such as desktop
+// notifications. Publishing never blocks the render loop.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"swaystats/theme"
+)
+
+// SeverityChange is published when a provider's severity level changes.
+type SeverityChange struct {
+	Module   string
+	Instance string
+	From     theme.Severity
+	To       theme.Severity
+	Value    float64 // sample that caused the change (percent for threshold-based modules)
+	At       time.Time
+}
+
+// Bus fans out events to subscribers.
+type Bus struct {
+	mu   sync.RWMutex
+	subs []chan SeverityChange
+}
+
+// Default is the process-wide bus used by providers.
+var Default = &Bus{}
+
+// Subscribe returns a channel receiving every subsequently published event.
+// Events are dropped for a subscriber whose buffer is full.
+func (b *Bus) Subscribe(buffer int) <-chan SeverityChange {
+	ch := make(chan SeverityChange, buffer)
+	b.mu.Lock()
+	b.subs = append(b.subs, ch)
+	b.mu.Unlock()
+	return ch
+}
+
+// Publish delivers e to all subscribers without blocking.
+func (b *Bus) Publish(e SeverityChange) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			// drop if full
+		}
+	}
+}
+
+// Subscribe subscribes to the Default bus.
+func Subscribe(buffer int) <-chan SeverityChange { return Default.Subscribe(buffer) }
+
+// Publish publishes on the Default bus.
+func Publish(e SeverityChange) { Default.Publish(e) }
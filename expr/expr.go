@@ -0,0 +1,396 @@
+// Package expr evaluates the small boolean expressions used by show_when and
+// profile conditions:
+// numbers, "strings", booleans, names (letters, digits, '_' and '.'),
+// function calls, arithmetic (+ - * /), comparisons (== != < <= > >=),
+// ! && || and parentheses, with the usual precedence.
+//
+//	cpu > 50
+//	on_battery && !iface_up("wg0")
+//	hour >= 9 && hour < 18
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Value is a float64, bool or string.
+type Value any
+
+// Env resolves the names and functions an expression refers to.
+type Env struct {
+	Vars  map[string]func() Value // evaluated lazily, only when referenced
+	Funcs map[string]func(args []Value) (Value, error)
+}
+
+// Expr is a parsed expression.
+type Expr struct {
+	src  string
+	eval func(env *Env) (Value, error)
+}
+
+func (e *Expr) String() string { return e.src }
+
+// Parse parses src.
+func Parse(src string) (*Expr, error) {
+	p := &parser{}
+	if err := p.lex(src); err != nil {
+		return nil, err
+	}
+	eval, err := p.parse(1)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return &Expr{src: src, eval: eval}, nil
+}
+
+// Eval evaluates the expression in env.
+func (e *Expr) Eval(env *Env) (Value, error) { return e.eval(env) }
+
+// Bool evaluates the expression, which must yield a boolean.
+func (e *Expr) Bool(env *Env) (bool, error) {
+	v, err := e.eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("result is %s, want bool", typeName(v))
+	}
+	return b, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokNum
+	tokStr
+	tokName
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	num  float64
+	pos  int
+}
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+// ops are the operator tokens, longest first.
+var ops = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "(", ")", ","}
+
+func (p *parser) lex(src string) error {
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			n, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return fmt.Errorf("bad number %q at %d", src[i:j], i)
+			}
+			p.toks = append(p.toks, token{kind: tokNum, text: src[i:j], num: n, pos: i})
+			i = j
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return fmt.Errorf("unterminated string at %d", i)
+			}
+			s, err := strconv.Unquote(src[i : j+1])
+			if err != nil {
+				return fmt.Errorf("bad string at %d: %w", i, err)
+			}
+			p.toks = append(p.toks, token{kind: tokStr, text: s, pos: i})
+			i = j + 1
+		case c == '_' || unicode.IsLetter(c):
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			p.toks = append(p.toks, token{kind: tokName, text: src[i:j], pos: i})
+			i = j
+		default:
+			op := ""
+			for _, o := range ops {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			p.toks = append(p.toks, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	p.toks = append(p.toks, token{kind: tokEOF, text: "end of expression", pos: len(src)})
+	return nil
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+// at reports whether the next token is the operator op.
+func (p *parser) at(op string) bool {
+	t := p.peek()
+	return t.kind == tokOp && t.text == op
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) expect(op string) error {
+	if t := p.next(); t.kind != tokOp || t.text != op {
+		return fmt.Errorf("want %q at %d, got %q", op, t.pos, t.text)
+	}
+	return nil
+}
+
+// precedence of binary operators; higher binds tighter.
+var precedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3,
+	"<": 4, "<=": 4, ">": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6,
+}
+
+type evalFunc = func(env *Env) (Value, error)
+
+// parse parses a binary expression whose operators bind at least as tightly as min.
+func (p *parser) parse(min int) (evalFunc, error) {
+	lhs, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		prec, ok := precedence[t.text]
+		if t.kind != tokOp || !ok || prec < min {
+			return lhs, nil
+		}
+		p.next()
+		rhs, err := p.parse(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+		lhs = binary(t.text, lhs, rhs)
+	}
+}
+
+func (p *parser) unary() (evalFunc, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokOp && (t.text == "!" || t.text == "-"):
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if t.text == "!" {
+			return func(env *Env) (Value, error) {
+				b, err := asBool(x(env))
+				return !b, err
+			}, nil
+		}
+		return func(env *Env) (Value, error) {
+			n, err := asNum(x(env))
+			return -n, err
+		}, nil
+	case t.kind == tokOp && t.text == "(":
+		x, err := p.parse(1)
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	case t.kind == tokNum:
+		return func(*Env) (Value, error) { return t.num, nil }, nil
+	case t.kind == tokStr:
+		return func(*Env) (Value, error) { return t.text, nil }, nil
+	case t.kind == tokName && t.text == "true", t.kind == tokName && t.text == "false":
+		b := t.text == "true"
+		return func(*Env) (Value, error) { return b, nil }, nil
+	case t.kind == tokName && p.at("("):
+		return p.call(t.text)
+	case t.kind == tokName:
+		return func(env *Env) (Value, error) {
+			v, ok := env.Vars[t.text]
+			if !ok {
+				return nil, fmt.Errorf("unknown name %q", t.text)
+			}
+			return v(), nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+func (p *parser) call(name string) (evalFunc, error) {
+	p.next() // (
+	var args []evalFunc
+	for !p.at(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		a, err := p.parse(1)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, a)
+	}
+	p.next() // )
+	return func(env *Env) (Value, error) {
+		fn, ok := env.Funcs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown function %q", name)
+		}
+		vals := make([]Value, len(args))
+		for i, a := range args {
+			v, err := a(env)
+			if err != nil {
+				return nil, err
+			}
+			vals[i] = v
+		}
+		return fn(vals)
+	}, nil
+}
+
+func binary(op string, lhs, rhs evalFunc) evalFunc {
+	switch op {
+	case "&&", "||": // short-circuit
+		return func(env *Env) (Value, error) {
+			l, err := asBool(lhs(env))
+			if err != nil || l == (op == "||") {
+				return l, err
+			}
+			return asBool(rhs(env))
+		}
+	}
+	return func(env *Env) (Value, error) {
+		l, err := lhs(env)
+		if err != nil {
+			return nil, err
+		}
+		r, err := rhs(env)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "==":
+			return l == r, nil
+		case "!=":
+			return l != r, nil
+		}
+		if ls, ok := l.(string); ok {
+			if rs, ok := r.(string); ok {
+				return compare(op, strings.Compare(ls, rs))
+			}
+		}
+		a, err := asNum(l, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		b, err := asNum(r, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		switch op {
+		case "+":
+			return a + b, nil
+		case "-":
+			return a - b, nil
+		case "*":
+			return a * b, nil
+		case "/":
+			if b == 0 {
+				return nil, errors.New("division by zero")
+			}
+			return a / b, nil
+		}
+		switch {
+		case a < b:
+			return compare(op, -1)
+		case a > b:
+			return compare(op, 1)
+		}
+		return compare(op, 0)
+	}
+}
+
+func compare(op string, c int) (Value, error) {
+	switch op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	}
+	return nil, fmt.Errorf("%s: operands must be numbers", op)
+}
+
+func asBool(v Value, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("got %s, want bool", typeName(v))
+	}
+	return b, nil
+}
+
+func asNum(v Value, err error) (float64, error) {
+	if err != nil {
+		return 0, err
+	}
+	n, ok := v.(float64)
+	if !ok {
+		return 0, fmt.Errorf("got %s, want number", typeName(v))
+	}
+	return n, nil
+}
+
+func typeName(v Value) string {
+	switch v.(type) {
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	}
+	return fmt.Sprintf("%T", v)
+}
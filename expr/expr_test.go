@@ -0,0 +1,194 @@
+package expr
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func testEnv() *Env {
+	return &Env{
+		Vars: map[string]func() Value{
+			"cpu":       func() Value { return 42.0 },
+			"name":      func() Value { return "laptop" },
+			"up":        func() Value { return true },
+			"disk.home": func() Value { return 91.5 },
+			"boom":      func() Value { panic("evaluated") },
+		},
+		Funcs: map[string]func([]Value) (Value, error){
+			"iface_up": func(args []Value) (Value, error) {
+				name, err := StringArg("iface_up", args)
+				return name == "wg0", err
+			},
+			"max": func(args []Value) (Value, error) {
+				m := 0.0
+				for _, a := range args {
+					if n := a.(float64); n > m {
+						m = n
+					}
+				}
+				return m, nil
+			},
+			"fail": func([]Value) (Value, error) { return nil, errors.New("failed") },
+		},
+	}
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{"1 + 2 * 3", 7.0},
+		{"(1 + 2) * 3", 9.0},
+		{"10 - 4 - 3", 3.0},
+		{"12 / 4 / 3", 1.0},
+		{"-cpu + 2", -40.0},
+		{"--3", 3.0},
+		{".5 * 4", 2.0},
+		{"cpu > 40", true},
+		{"cpu >= 42 && cpu <= 42", true},
+		{"cpu == 42", true},
+		{"cpu != 42", false},
+		{"1 + 1 == 2", true},
+		{"1 < 2 == true", true},
+		{"disk.home > 90", true},
+		{`name == "laptop"`, true},
+		{`name != "desktop"`, true},
+		{`"abc" < "abd"`, true},
+		{`"b" >= "a"`, true},
+		{`"a\"b"`, `a"b`},
+		{`1 == "1"`, false},
+		{"!up", false},
+		{"!!up", true},
+		{"up && cpu > 50 || name == \"laptop\"", true},
+		{"false || true && false", false},
+		{"true || boom", true},   // short-circuit
+		{"false && boom", false}, // short-circuit
+		{`iface_up("wg0")`, true},
+		{`!iface_up("eth0")`, true},
+		{"max(1, cpu, 7)", 42.0},
+		{"max()", 0.0},
+		{"max(1, max(2, 3)) + 1", 4.0},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		got, err := e.Eval(testEnv())
+		if err != nil {
+			t.Errorf("Eval(%q): %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"1 +",
+		"(1 + 2",
+		"1 + 2)",
+		"cpu > > 1",
+		"1 2",
+		`"open`,
+		"1.2.3",
+		"cpu # 1",
+		"f(1 2)",
+		"f(1,",
+		"&& up",
+	} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", src)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for _, src := range []string{
+		"missing > 1",
+		"nope(1)",
+		"fail() || true",
+		"cpu / 0",
+		`name + 1`,
+		`name > 1`,
+		"up < true",
+		"!cpu",
+		"-name",
+		"cpu && up",
+		`iface_up(1)`,
+		`iface_up("a", "b")`,
+	} {
+		e, err := Parse(src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", src, err)
+			continue
+		}
+		if v, err := e.Eval(testEnv()); err == nil {
+			t.Errorf("Eval(%q) = %v, want an error", src, v)
+		}
+	}
+}
+
+func TestBool(t *testing.T) {
+	tests := []struct {
+		src     string
+		want    bool
+		wantErr bool
+	}{
+		{"cpu > 40", true, false},
+		{"up && false", false, false},
+		{"cpu", false, true},
+		{`name`, false, true},
+		{"missing", false, true},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := e.Bool(testEnv())
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("Bool(%q) = %v, %v; want %v, error %v", tt.src, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSystem(t *testing.T) {
+	t.Setenv("EXPR_TEST", "yes")
+	env := System(time.Date(2026, 10, 18, 14, 30, 0, 0, time.UTC)) // a Sunday
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"hour == 14 && minute == 30", true},
+		{"weekday == 0", true},
+		{`env("EXPR_TEST") == "yes"`, true},
+		{`env("EXPR_TEST_UNSET") == ""`, true},
+		{`file_exists("/")`, true},
+		{`file_exists("/nonexistent/swaystats")`, false},
+		{`iface_up("swaystats-none")`, false},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := e.Bool(env)
+		if err != nil || got != tt.want {
+			t.Errorf("%q = %v, %v; want %v", tt.src, got, err, tt.want)
+		}
+	}
+	e, err := Parse(`env()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Eval(env); err == nil {
+		t.Error("env() without an argument succeeded")
+	}
+}
@@ -0,0 +1,89 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"swaystats/config"
+)
+
+// System returns an Env with facts about the machine at now:
+//
+//	hour, minute, weekday (0 = Sunday), hostname, on_battery
+//	iface_up("wg0"), file_exists("~/path"), env("NAME")
+//
+// Callers add their own names to the returned maps.
+func System(now time.Time) *Env {
+	return &Env{
+		Vars: map[string]func() Value{
+			"hour":       func() Value { return float64(now.Hour()) },
+			"minute":     func() Value { return float64(now.Minute()) },
+			"weekday":    func() Value { return float64(now.Weekday()) },
+			"on_battery": func() Value { return onBattery() },
+			"hostname": func() Value {
+				h, _ := os.Hostname()
+				return h
+			},
+		},
+		Funcs: map[string]func([]Value) (Value, error){
+			"iface_up": func(args []Value) (Value, error) {
+				name, err := StringArg("iface_up", args)
+				if err != nil {
+					return nil, err
+				}
+				ifi, err := net.InterfaceByName(name)
+				return err == nil && ifi.Flags&net.FlagUp != 0, nil
+			},
+			"file_exists": func(args []Value) (Value, error) {
+				path, err := StringArg("file_exists", args)
+				if err != nil {
+					return nil, err
+				}
+				_, err = os.Stat(config.ExpandHome(path))
+				return err == nil, nil
+			},
+			"env": func(args []Value) (Value, error) {
+				name, err := StringArg("env", args)
+				if err != nil {
+					return nil, err
+				}
+				return os.Getenv(name), nil
+			},
+		},
+	}
+}
+
+// StringArg returns the single string argument of a call to fn.
+func StringArg(fn string, args []Value) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("%s: want 1 argument, got %d", fn, len(args))
+	}
+	s, ok := args[0].(string)
+	if !ok {
+		return "", errors.New(fn + ": argument must be a string")
+	}
+	return s, nil
+}
+
+// onBattery reports whether a mains supply exists and none is online.
+func onBattery() bool {
+	supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+	mains := false
+	for _, dir := range supplies {
+		typ, err := os.ReadFile(filepath.Join(dir, "type"))
+		if err != nil || strings.TrimSpace(string(typ)) != "Mains" {
+			continue
+		}
+		mains = true
+		online, err := os.ReadFile(filepath.Join(dir, "online"))
+		if err == nil && strings.TrimSpace(string(online)) == "1" {
+			return false
+		}
+	}
+	return mains
+}
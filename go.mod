@@ -5,6 +5,9 @@ go 1.25.2
 require (
 	github.com/BurntSushi/toml v1.5.0
 	github.com/fsnotify/fsnotify v1.7.0
+	github.com/godbus/dbus/v5 v5.1.0
+	go.starlark.net v0.0.0-20260908191801-89a6a09411d5
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require golang.org/x/sys v0.4.0 // indirect
+require golang.org/x/sys v0.42.0 // indirect
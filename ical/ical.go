@@ -0,0 +1,287 @@
+// Package ical parses the subset of iCalendar (RFC 5545) needed to find
+// upcoming events: VEVENT components with DTSTART/DTEND/DURATION, common
+// RRULE recurrences, EXDATE exclusions and RECURRENCE-ID overrides.
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is one VEVENT as written in the file (recurrences not yet expanded).
+type Event struct {
+	UID          string
+	Summary      string
+	Location     string
+	Start        time.Time
+	End          time.Time // exclusive; derived from DURATION or defaults when DTEND is absent
+	AllDay       bool
+	Cancelled    bool
+	Rule         *Rule       // nil if not recurring
+	ExDates      []time.Time // excluded occurrence starts
+	RecurrenceID time.Time   // non-zero for an override of one occurrence of UID
+
+	duration time.Duration // DURATION value, applied once DTSTART is known
+}
+
+// Parse reads all VEVENT components from r. Malformed properties are skipped;
+// an error is returned only if reading fails or no calendar data was found.
+func Parse(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		events   []Event
+		cur      *Event
+		depth    int // nesting inside VEVENT (VALARM etc.)
+		sawBegin bool
+	)
+	for _, line := range lines {
+		name, params, value, ok := splitProperty(line)
+		if !ok {
+			continue
+		}
+		switch name {
+		case "BEGIN":
+			sawBegin = true
+			if cur != nil {
+				depth++
+			} else if strings.EqualFold(value, "VEVENT") {
+				cur = &Event{}
+			}
+			continue
+		case "END":
+			if cur == nil {
+				continue
+			}
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if strings.EqualFold(value, "VEVENT") {
+				if !cur.Start.IsZero() {
+					finishEvent(cur)
+					events = append(events, *cur)
+				}
+				cur = nil
+			}
+			continue
+		}
+		if cur == nil || depth > 0 {
+			continue
+		}
+		switch name {
+		case "UID":
+			cur.UID = value
+		case "SUMMARY":
+			cur.Summary = unescapeText(value)
+		case "LOCATION":
+			cur.Location = unescapeText(value)
+		case "STATUS":
+			cur.Cancelled = strings.EqualFold(value, "CANCELLED")
+		case "DTSTART":
+			if t, allDay, err := parseDateTime(value, params); err == nil {
+				cur.Start, cur.AllDay = t, allDay
+			}
+		case "DTEND":
+			if t, _, err := parseDateTime(value, params); err == nil {
+				cur.End = t
+			}
+		case "DURATION":
+			if d, err := parseDuration(value); err == nil {
+				cur.duration = d
+			}
+		case "RRULE":
+			if rule, err := parseRule(value, params); err == nil {
+				cur.Rule = rule
+			}
+		case "EXDATE":
+			for _, v := range strings.Split(value, ",") {
+				if t, _, err := parseDateTime(v, params); err == nil {
+					cur.ExDates = append(cur.ExDates, t)
+				}
+			}
+		case "RECURRENCE-ID":
+			if t, _, err := parseDateTime(value, params); err == nil {
+				cur.RecurrenceID = t
+			}
+		}
+	}
+	if !sawBegin {
+		return nil, errors.New("ical: no calendar data")
+	}
+	return events, nil
+}
+
+// finishEvent fills in End when it was omitted or given as a DURATION.
+func finishEvent(ev *Event) {
+	switch {
+	case !ev.End.IsZero():
+	case ev.duration > 0:
+		ev.End = ev.Start.Add(ev.duration)
+	case ev.AllDay:
+		ev.End = ev.Start.AddDate(0, 0, 1)
+	default:
+		ev.End = ev.Start
+	}
+	if ev.End.Before(ev.Start) {
+		ev.End = ev.Start
+	}
+}
+
+// unfold joins continuation lines (RFC 5545 §3.1) and strips CRLF.
+func unfold(r io.Reader) ([]string, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var lines []string
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, sc.Err()
+}
+
+// splitProperty splits `NAME;P1=V1;P2="V2":VALUE` into its parts.
+// Parameter names are upper-cased; quoted values may contain ';' and ':'.
+func splitProperty(line string) (name string, params map[string]string, value string, ok bool) {
+	inQuote := false
+	colon := -1
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			inQuote = !inQuote
+		case ':':
+			if !inQuote {
+				colon = i
+			}
+		}
+		if colon >= 0 {
+			break
+		}
+	}
+	if colon < 0 {
+		return "", nil, "", false
+	}
+	head, value := line[:colon], line[colon+1:]
+	parts := strings.Split(head, ";")
+	name = strings.ToUpper(parts[0])
+	for _, p := range parts[1:] {
+		k, v, found := strings.Cut(p, "=")
+		if !found {
+			continue
+		}
+		if params == nil {
+			params = map[string]string{}
+		}
+		params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+	return name, params, value, name != ""
+}
+
+// parseDateTime parses DATE or DATE-TIME values honoring TZID and the UTC 'Z'
+// suffix. Floating times and unknown TZIDs use the local zone.
+func parseDateTime(value string, params map[string]string) (t time.Time, allDay bool, err error) {
+	value = strings.TrimSpace(value)
+	loc := time.Local
+	if tzid := params["TZID"]; tzid != "" {
+		if l, lerr := time.LoadLocation(tzid); lerr == nil {
+			loc = l
+		}
+	}
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err = time.ParseInLocation("20060102", value, time.Local)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	t, err = time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+// parseDuration parses RFC 5545 durations such as PT1H30M, P1D or -PT15M.
+func parseDuration(s string) (time.Duration, error) {
+	neg := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		neg = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") {
+		return 0, errors.New("ical: bad duration")
+	}
+	s = s[1:]
+	var d time.Duration
+	num := 0
+	haveNum := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= '0' && c <= '9' {
+			num = num*10 + int(c-'0')
+			haveNum = true
+			continue
+		}
+		var unit time.Duration
+		switch c {
+		case 'T':
+			continue
+		case 'W':
+			unit = 7 * 24 * time.Hour
+		case 'D':
+			unit = 24 * time.Hour
+		case 'H':
+			unit = time.Hour
+		case 'M':
+			unit = time.Minute
+		case 'S':
+			unit = time.Second
+		default:
+			return 0, errors.New("ical: bad duration")
+		}
+		if !haveNum {
+			return 0, errors.New("ical: bad duration")
+		}
+		d += time.Duration(num) * unit
+		num, haveNum = 0, false
+	}
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// unescapeText reverses TEXT escaping (\n, \, , \; and \\).
+func unescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			sb.WriteByte(' ')
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+func atoi(s string) (int, error) { return strconv.Atoi(strings.TrimSpace(s)) }
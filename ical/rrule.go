@@ -0,0 +1,327 @@
+package ical
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rule is a parsed RRULE. Supported: FREQ (DAILY, WEEKLY, MONTHLY, YEARLY),
+// INTERVAL, COUNT, UNTIL, BYDAY (with ordinals for MONTHLY/YEARLY),
+// BYMONTHDAY and BYMONTH. Other parts are ignored.
+type Rule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+}
+
+// WeekdayNum is a BYDAY entry such as MO, 2TU or -1FR (N == 0 means every).
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+// Occurrence is one concrete instance of an event.
+type Occurrence struct {
+	Event *Event
+	Start time.Time
+	End   time.Time
+}
+
+// maxIterations bounds rule expansion so malformed rules cannot spin forever.
+const maxIterations = 100000
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+func parseRule(value string, params map[string]string) (*Rule, error) {
+	r := &Rule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToUpper(k) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(v)
+		case "INTERVAL":
+			if n, err := atoi(v); err == nil && n > 0 {
+				r.Interval = n
+			}
+		case "COUNT":
+			if n, err := atoi(v); err == nil && n > 0 {
+				r.Count = n
+			}
+		case "UNTIL":
+			if t, _, err := parseDateTime(v, params); err == nil {
+				r.Until = t
+			}
+		case "BYDAY":
+			for _, d := range strings.Split(v, ",") {
+				d = strings.ToUpper(strings.TrimSpace(d))
+				if len(d) < 2 {
+					continue
+				}
+				wd, ok := weekdays[d[len(d)-2:]]
+				if !ok {
+					continue
+				}
+				n := 0
+				if len(d) > 2 {
+					var err error
+					if n, err = atoi(strings.TrimPrefix(d[:len(d)-2], "+")); err != nil {
+						continue
+					}
+				}
+				r.ByDay = append(r.ByDay, WeekdayNum{N: n, Day: wd})
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(v, ",") {
+				if n, err := atoi(d); err == nil && n != 0 && n >= -31 && n <= 31 {
+					r.ByMonthDay = append(r.ByMonthDay, n)
+				}
+			}
+		case "BYMONTH":
+			for _, m := range strings.Split(v, ",") {
+				if n, err := atoi(m); err == nil && n >= 1 && n <= 12 {
+					r.ByMonth = append(r.ByMonth, time.Month(n))
+				}
+			}
+		}
+	}
+	switch r.Freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+		return r, nil
+	}
+	return nil, errors.New("ical: unsupported FREQ " + r.Freq)
+}
+
+// Expand returns occurrences overlapping [from, to), sorted by start.
+// Overrides (events with RecurrenceID) replace the matching occurrence of
+// their UID; cancelled events and cancelled overrides are dropped.
+func Expand(events []Event, from, to time.Time) []Occurrence {
+	type key struct {
+		uid string
+		at  int64
+	}
+	overrides := map[key]*Event{}
+	for i := range events {
+		ev := &events[i]
+		if !ev.RecurrenceID.IsZero() && ev.UID != "" {
+			overrides[key{ev.UID, ev.RecurrenceID.Unix()}] = ev
+		}
+	}
+	var out []Occurrence
+	add := func(ev *Event, start, end time.Time) {
+		if ev.Cancelled || !start.Before(to) {
+			return
+		}
+		if end.After(from) || !start.Before(from) { // zero-length events count at their start
+			out = append(out, Occurrence{Event: ev, Start: start, End: end})
+		}
+	}
+	for i := range events {
+		ev := &events[i]
+		if !ev.RecurrenceID.IsZero() {
+			continue // emitted via its master below (or standalone if orphaned)
+		}
+		length := ev.End.Sub(ev.Start)
+		emit := func(start time.Time) {
+			if ev.UID != "" {
+				if o, ok := overrides[key{ev.UID, start.Unix()}]; ok {
+					add(o, o.Start, o.End)
+					delete(overrides, key{ev.UID, start.Unix()})
+					return
+				}
+			}
+			for _, ex := range ev.ExDates {
+				if ex.Equal(start) || ev.AllDay && sameDate(ex, start) {
+					return
+				}
+			}
+			add(ev, start, start.Add(length))
+		}
+		if ev.Rule == nil {
+			emit(ev.Start)
+			continue
+		}
+		ev.Rule.each(ev.Start, to, emit)
+	}
+	// Orphaned overrides (master missing or rule did not produce the instance).
+	for _, o := range overrides {
+		add(o, o.Start, o.End)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out
+}
+
+// each calls fn for every occurrence start from dtstart until limit, honoring
+// COUNT and UNTIL. Times keep dtstart's wall clock in its location across DST.
+func (r *Rule) each(dtstart, limit time.Time, fn func(time.Time)) {
+	loc := dtstart.Location()
+	h, m, s := dtstart.Clock()
+	at := func(y int, mo time.Month, d int) time.Time { return time.Date(y, mo, d, h, m, s, 0, loc) }
+	count := 0
+	done := func(t time.Time) bool {
+		return !r.Until.IsZero() && t.After(r.Until) || r.Count > 0 && count >= r.Count || !t.Before(limit)
+	}
+	visit := func(days []time.Time) bool {
+		sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+		for _, t := range days {
+			if t.Before(dtstart) {
+				continue
+			}
+			if done(t) {
+				return false
+			}
+			count++
+			fn(t)
+		}
+		return true
+	}
+	y, mo, d := dtstart.Date()
+	for i := 0; i < maxIterations; i++ {
+		var cands []time.Time
+		var periodStart time.Time
+		switch r.Freq {
+		case "DAILY":
+			t := at(y, mo, d+i*r.Interval)
+			periodStart = t
+			if r.matchMonth(t.Month()) && r.matchWeekday(t.Weekday()) && r.matchMonthDay(t) {
+				cands = append(cands, t)
+			}
+		case "WEEKLY":
+			// Weeks start on Monday (WKST default).
+			offset := (int(dtstart.Weekday()) + 6) % 7
+			monday := at(y, mo, d-offset+7*i*r.Interval)
+			periodStart = monday
+			if len(r.ByDay) == 0 {
+				if t := at(y, mo, d+7*i*r.Interval); r.matchMonth(t.Month()) {
+					cands = append(cands, t)
+				}
+				break
+			}
+			for k := 0; k < 7; k++ {
+				t := at(monday.Year(), monday.Month(), monday.Day()+k)
+				if r.matchWeekday(t.Weekday()) && r.matchMonth(t.Month()) {
+					cands = append(cands, t)
+				}
+			}
+		case "MONTHLY":
+			first := at(y, mo+time.Month(i*r.Interval), 1)
+			periodStart = first
+			if r.matchMonth(first.Month()) {
+				cands = r.daysInMonth(first, d, at)
+			}
+		case "YEARLY":
+			year := y + i*r.Interval
+			periodStart = at(year, 1, 1)
+			months := r.ByMonth
+			if len(months) == 0 {
+				months = []time.Month{mo}
+			}
+			for _, month := range months {
+				cands = append(cands, r.daysInMonth(at(year, month, 1), d, at)...)
+			}
+		default:
+			return
+		}
+		if !periodStart.Before(limit) || !r.Until.IsZero() && periodStart.After(r.Until) {
+			return
+		}
+		if !visit(cands) {
+			return
+		}
+	}
+}
+
+// daysInMonth returns candidate days within the month starting at first.
+func (r *Rule) daysInMonth(first time.Time, dtDay int, at func(int, time.Month, int) time.Time) []time.Time {
+	y, mo := first.Year(), first.Month()
+	last := at(y, mo+1, 0).Day()
+	var out []time.Time
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, md := range r.ByMonthDay {
+			day := md
+			if md < 0 {
+				day = last + md + 1
+			}
+			if day >= 1 && day <= last && (len(r.ByDay) == 0 || r.matchWeekday(at(y, mo, day).Weekday())) {
+				out = append(out, at(y, mo, day))
+			}
+		}
+	case len(r.ByDay) > 0:
+		for _, wd := range r.ByDay {
+			var matches []time.Time
+			for day := 1; day <= last; day++ {
+				if t := at(y, mo, day); t.Weekday() == wd.Day {
+					matches = append(matches, t)
+				}
+			}
+			switch {
+			case wd.N == 0:
+				out = append(out, matches...)
+			case wd.N > 0 && wd.N <= len(matches):
+				out = append(out, matches[wd.N-1])
+			case wd.N < 0 && -wd.N <= len(matches):
+				out = append(out, matches[len(matches)+wd.N])
+			}
+		}
+	default:
+		if dtDay <= last {
+			out = append(out, at(y, mo, dtDay))
+		}
+	}
+	return out
+}
+
+func (r *Rule) matchWeekday(wd time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, b := range r.ByDay {
+		if b.Day == wd {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchMonth(m time.Month) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, b := range r.ByMonth {
+		if b == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	last := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	for _, md := range r.ByMonthDay {
+		if md == t.Day() || md < 0 && last+md+1 == t.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+func sameDate(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
@@ -0,0 +1,166 @@
+package ical
+
+import (
+	"slices"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestRuleEach(t *testing.T) {
+	utc := func(s string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart string
+		limit   string
+		want    []string
+	}{
+		{"daily count", "FREQ=DAILY;COUNT=3", "2026-01-30 09:00", "2027-01-01 00:00",
+			[]string{"2026-01-30 09:00", "2026-01-31 09:00", "2026-02-01 09:00"}},
+		{"daily interval until", "FREQ=DAILY;INTERVAL=2;UNTIL=20260105T090000Z", "2026-01-01 09:00", "2027-01-01 00:00",
+			[]string{"2026-01-01 09:00", "2026-01-03 09:00", "2026-01-05 09:00"}},
+		{"weekly byday", "FREQ=WEEKLY;BYDAY=MO,FR;COUNT=4", "2026-03-04 10:00", "2027-01-01 00:00",
+			[]string{"2026-03-06 10:00", "2026-03-09 10:00", "2026-03-13 10:00", "2026-03-16 10:00"}},
+		{"weekly bymonth without byday", "FREQ=WEEKLY;BYMONTH=3;COUNT=6", "2026-02-18 08:00", "2027-01-01 00:00",
+			[]string{"2026-03-04 08:00", "2026-03-11 08:00", "2026-03-18 08:00", "2026-03-25 08:00"}},
+		{"weekly bymonth limit", "FREQ=WEEKLY;BYMONTH=1", "2026-01-21 08:00", "2026-02-20 00:00",
+			[]string{"2026-01-21 08:00", "2026-01-28 08:00"}},
+		{"monthly last friday", "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", "2026-01-01 17:00", "2027-01-01 00:00",
+			[]string{"2026-01-30 17:00", "2026-02-27 17:00", "2026-03-27 17:00"}},
+		{"monthly second to last monday", "FREQ=MONTHLY;BYDAY=-2MO;COUNT=2", "2026-06-01 12:00", "2027-01-01 00:00",
+			[]string{"2026-06-22 12:00", "2026-07-20 12:00"}},
+		{"monthly second tuesday", "FREQ=MONTHLY;BYDAY=2TU;COUNT=2", "2026-01-01 12:00", "2027-01-01 00:00",
+			[]string{"2026-01-13 12:00", "2026-02-10 12:00"}},
+		{"monthly fifth monday skips short months", "FREQ=MONTHLY;BYDAY=5MO;COUNT=2", "2026-01-01 12:00", "2027-01-01 00:00",
+			[]string{"2026-03-30 12:00", "2026-06-29 12:00"}},
+		{"monthly last day", "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=3", "2026-01-15 18:00", "2027-01-01 00:00",
+			[]string{"2026-01-31 18:00", "2026-02-28 18:00", "2026-03-31 18:00"}},
+		{"monthly second to last day, leap year", "FREQ=MONTHLY;BYMONTHDAY=-2;COUNT=2", "2028-02-01 18:00", "2029-01-01 00:00",
+			[]string{"2028-02-28 18:00", "2028-03-30 18:00"}},
+		{"monthly 31st skips short months", "FREQ=MONTHLY;COUNT=3", "2026-01-31 07:00", "2027-01-01 00:00",
+			[]string{"2026-01-31 07:00", "2026-03-31 07:00", "2026-05-31 07:00"}},
+		{"monthday and weekday", "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13;COUNT=2", "2026-01-01 00:00", "2028-01-01 00:00",
+			[]string{"2026-02-13 00:00", "2026-03-13 00:00"}},
+		{"yearly bymonth", "FREQ=YEARLY;BYMONTH=3,9;BYDAY=1SU;COUNT=3", "2026-01-01 10:00", "2030-01-01 00:00",
+			[]string{"2026-03-01 10:00", "2026-09-06 10:00", "2027-03-07 10:00"}},
+		{"yearly feb 29", "FREQ=YEARLY;COUNT=2", "2028-02-29 09:00", "2040-01-01 00:00",
+			[]string{"2028-02-29 09:00", "2032-02-29 09:00"}},
+		{"daily bymonthday negative", "FREQ=DAILY;BYMONTHDAY=-1;COUNT=2", "2026-04-01 09:00", "2027-01-01 00:00",
+			[]string{"2026-04-30 09:00", "2026-05-31 09:00"}},
+		{"limit", "FREQ=DAILY", "2026-01-01 09:00", "2026-01-03 09:00",
+			[]string{"2026-01-01 09:00", "2026-01-02 09:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := parseRule(tt.rule, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			r.each(utc(tt.dtstart), utc(tt.limit), func(at time.Time) {
+				got = append(got, at.Format("2006-01-02 15:04"))
+			})
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleEachDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := parseRule("FREQ=DAILY;COUNT=3", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Clocks go forward on 2026-03-29: the wall clock stays at 09:00 while
+	// the UTC offset changes.
+	var got []string
+	r.each(time.Date(2026, 3, 28, 9, 0, 0, 0, berlin), time.Date(2027, 1, 1, 0, 0, 0, 0, berlin), func(at time.Time) {
+		got = append(got, at.Format(time.RFC3339))
+	})
+	want := []string{"2026-03-28T09:00:00+01:00", "2026-03-29T09:00:00+02:00", "2026-03-30T09:00:00+02:00"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	day := func(d int, h int) time.Time { return time.Date(2026, 5, d, h, 0, 0, 0, time.UTC) }
+	rule := func(s string) *Rule {
+		r, err := parseRule(s, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	tests := []struct {
+		name     string
+		events   []Event
+		from, to time.Time
+		want     []time.Time
+	}{
+		{
+			name:   "count spent before from",
+			events: []Event{{UID: "a", Start: day(1, 9), End: day(1, 10), Rule: rule("FREQ=DAILY;COUNT=3")}},
+			from:   day(4, 0), to: day(10, 0),
+		},
+		{
+			name:   "count partly before from",
+			events: []Event{{UID: "a", Start: day(1, 9), End: day(1, 10), Rule: rule("FREQ=DAILY;COUNT=5")}},
+			from:   day(4, 0), to: day(10, 0),
+			want: []time.Time{day(4, 9), day(5, 9)},
+		},
+		{
+			name:   "running occurrence overlaps from",
+			events: []Event{{UID: "a", Start: day(1, 9), End: day(1, 12), Rule: rule("FREQ=DAILY")}},
+			from:   day(3, 10), to: day(4, 10),
+			want: []time.Time{day(3, 9), day(4, 9)},
+		},
+		{
+			name:   "exdate",
+			events: []Event{{UID: "a", Start: day(1, 9), End: day(1, 10), Rule: rule("FREQ=DAILY;COUNT=3"), ExDates: []time.Time{day(2, 9)}}},
+			from:   day(1, 0), to: day(10, 0),
+			want: []time.Time{day(1, 9), day(3, 9)},
+		},
+		{
+			name: "override moves an occurrence",
+			events: []Event{
+				{UID: "a", Start: day(1, 9), End: day(1, 10), Rule: rule("FREQ=DAILY;COUNT=3")},
+				{UID: "a", Start: day(2, 15), End: day(2, 16), RecurrenceID: day(2, 9)},
+			},
+			from: day(1, 0), to: day(10, 0),
+			want: []time.Time{day(1, 9), day(2, 15), day(3, 9)},
+		},
+		{
+			name: "cancelled override",
+			events: []Event{
+				{UID: "a", Start: day(1, 9), End: day(1, 10), Rule: rule("FREQ=DAILY;COUNT=3")},
+				{UID: "a", Start: day(2, 9), End: day(2, 10), RecurrenceID: day(2, 9), Cancelled: true},
+			},
+			from: day(1, 0), to: day(10, 0),
+			want: []time.Time{day(1, 9), day(3, 9)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []time.Time
+			for _, o := range Expand(tt.events, tt.from, tt.to) {
+				got = append(got, o.Start)
+			}
+			if !slices.EqualFunc(got, tt.want, time.Time.Equal) {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Package logfile provides a size-rotated append-only log file.
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Writer appends to path, rotating it to path.1 … path.<keep> once it would
+// grow beyond maxBytes. It is safe for concurrent use.
+type Writer struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64 // 0 disables rotation
+	keep     int
+	f        *os.File
+	size     int64
+}
+
+// Open opens (creating directories as needed) a rotating log file.
+func Open(path string, maxBytes int64, keep int) (*Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	w := &Writer{path: path, maxBytes: maxBytes, keep: max(keep, 0)}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.f, w.size = f, info.Size()
+	return nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.maxBytes > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxBytes {
+		if err := w.rotate(); err != nil {
+			// Keep logging to the current file rather than losing lines.
+			fmt.Fprintf(os.Stderr, "swaystats: rotate log: %v\n", err)
+		}
+	}
+	if w.f == nil {
+		return 0, os.ErrClosed
+	}
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate shifts path.N to path.N+1 (dropping the oldest) and starts a new file.
+func (w *Writer) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	w.f = nil
+	if w.keep == 0 {
+		_ = os.Remove(w.path)
+	} else {
+		_ = os.Remove(fmt.Sprintf("%s.%d", w.path, w.keep))
+		for i := w.keep - 1; i >= 1; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+		}
+		if err := os.Rename(w.path, w.path+".1"); err != nil {
+			_ = w.open()
+			return err
+		}
+	}
+	return w.open()
+}
+
+// Close closes the underlying file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
@@ -1,40 +1,331 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"swaystats/blocks"
 	"swaystats/clicks"
 	"swaystats/config"
-
-	"github.com/fsnotify/fsnotify"
+	"swaystats/events"
+	"swaystats/metrics"
+	"swaystats/migrate"
+	"swaystats/notify"
+	"swaystats/output"
+	"swaystats/profile"
+	"swaystats/stats"
 )
 
 func main() {
-	log.SetOutput(os.Stderr)
-	cfg, err := config.Load("")
+	cmd, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	switch cmd {
+	case "run":
+		runBar(args)
+	case "once":
+		os.Exit(runOnce(args))
+	case "watch":
+		runWatch(args)
+	case "profile":
+		os.Exit(runProfile(args))
+	case "convert":
+		os.Exit(runConvert(args))
+	case "import":
+		os.Exit(runImport(args))
+	case "schema":
+		parseFlags("schema", args)
+		if err := config.WriteSchema(os.Stdout); err != nil {
+			slog.Error("schema", "err", err)
+			os.Exit(1)
+		}
+	default:
+		slog.Error("unknown command (want run, once, watch, profile, convert, import or schema)", "command", cmd)
+		os.Exit(2)
+	}
+}
+
+// runBar is the default mode: a status line for a bar, forever.
+func runBar(args []string) {
+	o := parseFlags("run", args)
+	cfg := o.loadConfig()
+	out := newBackend(cfg, o)
+
+	// Protocol header (i3bar) or nothing, depending on the backend.
+	if err := out.Start(os.Stdout); err != nil {
+		slog.Error("write header", "err", err)
+	}
+	loop(cfg, o, out.Clicks(), func(row []blocks.Block, changed bool, _ []blocks.Provider) bool {
+		if err := out.Row(os.Stdout, row); err != nil {
+			slog.Error("write row", "err", err)
+		}
+		return true
+	})
+}
+
+// runWatch prints a row (or with --json a full state object) whenever a block
+// changes, without any bar protocol framing.
+func runWatch(args []string) {
+	o := parseFlags("watch", args)
+	cfg := o.loadConfig()
+	out := newBackend(cfg, o)
+	first := true
+	loop(cfg, o, false, func(row []blocks.Block, changed bool, providers []blocks.Provider) bool {
+		if !changed && !first {
+			return false
+		}
+		first = false
+		var err error
+		if o.json {
+			err = output.WriteState(os.Stdout, time.Now(), row, blocks.CollectMetrics(providers))
+		} else {
+			err = out.Row(os.Stdout, row)
+		}
+		if err != nil {
+			slog.Error("write row", "err", err)
+		}
+		return true
+	})
+}
+
+// onceWarmup is how long `once` lets delta-based providers (CPU) accumulate
+// before taking the sample it prints.
+const onceWarmup = 250 * time.Millisecond
+
+// runOnce samples every enabled provider, prints one row and returns the exit code.
+func runOnce(args []string) int {
+	o := parseFlags("once", args)
+	cfg := o.loadConfig()
+	out := newBackend(cfg, o)
+	active, _ := profile.NewSelector().Select(cfg)
+	providers := blocks.BuildProviders(cfg.WithProfile(active))
+	defer blocks.CloseProviders(providers)
+	var warmers []blocks.Warmer
+	for _, p := range providers {
+		if w, ok := p.(blocks.Warmer); ok {
+			warmers = append(warmers, w)
+		}
+	}
+	if len(warmers) > 0 {
+		time.Sleep(onceWarmup)
+		now := time.Now().UnixNano()
+		for _, w := range warmers {
+			w.Warm(now)
+		}
+	}
+	row, _ := refresh(providers)
+	blocks.NewMarkup().Apply(cfg, providers, row)
+	row, _ = blocks.NewVisibility().Filter(cfg, providers, row)
+	var err error
+	if o.json {
+		err = output.WriteState(os.Stdout, time.Now(), row, blocks.CollectMetrics(providers))
+	} else {
+		err = out.Row(os.Stdout, row)
+	}
+	if err != nil {
+		slog.Error("write row", "err", err)
+		return 1
+	}
+	return 0
+}
+
+// runProfile lists the profiles (marking the one that would be active) or,
+// given a name or "auto", sets the manual choice picked up by running bars.
+func runProfile(args []string) int {
+	o := parseFlags("profile", args)
+	cfg := o.loadConfig()
+	if len(o.args) == 0 {
+		active, manual := profile.NewSelector().Select(cfg)
+		for _, name := range cfg.ProfileNames() {
+			mark, how := " ", ""
+			if name == active {
+				mark, how = "*", " (auto)"
+				if manual {
+					how = " (manual)"
+				}
+			}
+			fmt.Printf("%s %s%s\n", mark, name, how)
+		}
+		return 0
+	}
+	name := o.args[0]
+	if _, ok := cfg.Profiles[name]; !ok && name != profile.Auto {
+		slog.Error("unknown profile", "profile", name, "known", cfg.ProfileNames())
+		return 1
+	}
+	if err := profile.SetManual(name); err != nil {
+		slog.Error("set profile", "err", err)
+		return 1
+	}
+	return 0
+}
+
+// runConvert prints a config file (the argument, else --config, else the
+// first one found) in another format.
+func runConvert(args []string) int {
+	o := parseFlags("convert", args)
+	path := o.configPath
+	if len(o.args) > 0 {
+		path = o.args[0]
+	}
+	if path == "" {
+		for _, p := range config.Candidates("") {
+			if _, err := os.Stat(p); err == nil {
+				path = p
+				break
+			}
+		}
+	}
+	if path == "" {
+		slog.Error("convert: no config file found")
+		return 1
+	}
+	if err := config.Convert(os.Stdout, path, o.from, o.to); err != nil {
+		slog.Error("convert", "path", path, "err", err)
+		return 1
+	}
+	return 0
+}
+
+// runImport prints an i3status or i3blocks config (the argument, else the
+// usual location of that format) as swaystats TOML.
+func runImport(args []string) int {
+	o := parseFlags("import", args)
+	var path string
+	if len(o.args) > 0 {
+		path = o.args[0]
+	} else {
+		home, _ := os.UserHomeDir()
+		for _, p := range map[string][]string{
+			"i3status": {filepath.Join(home, ".config", "i3status", "config"), "/etc/i3status.conf"},
+			"i3blocks": {filepath.Join(home, ".config", "i3blocks", "config"), "/etc/i3blocks.conf"},
+		}[o.from] {
+			if _, err := os.Stat(p); err == nil {
+				path = p
+				break
+			}
+		}
+	}
+	if o.from == "" || path == "" {
+		slog.Error("usage: swaystats import --from i3status|i3blocks [file]")
+		return 1
+	}
+	f, err := os.Open(path)
 	if err != nil {
-		log.Printf("config: %v", err)
+		slog.Error("import", "err", err)
+		return 1
+	}
+	defer f.Close()
+	if err := migrate.Import(os.Stdout, f, o.from); err != nil {
+		slog.Error("import", "path", path, "err", err)
+		return 1
 	}
+	return 0
+}
 
-	// Build providers using registry + config order (held atomically for live reloads).
-	var providers atomic.Value // []blocks.Provider
-	providers.Store(blocks.BuildProviders(cfg))
+// newBackend picks the output backend: flag, else config, else i3bar.
+func newBackend(cfg *config.Config, o *options) output.Backend {
+	format := cfg.Output.Format
+	if o.output != "" {
+		format = o.output
+	}
+	opts := output.Options{Separator: cfg.Output.Separator, NoClicks: o.noClicks}
+	out, err := output.New(format, opts)
+	if err != nil {
+		slog.Warn("bad output format; using i3bar", "err", err)
+		out, _ = output.New("i3bar", opts)
+	}
+	return out
+}
 
-	// i3bar protocol header and opening array.
-	fmt.Println(`{"version":1,"click_events":true}`)
-	fmt.Println("[")
-	fmt.Println("[]")
+// profileCheckInterval is how often profile conditions (and the manual
+// choice) are re-evaluated.
+const profileCheckInterval = 2 * time.Second
 
+// loop runs providers at tick_hz forever, calling emit after every tick with
+// the current row and whether any block changed; emit reports whether it
+// wrote a row. Config reloads, clicks (if readClicks), notifications and the
+// metrics exporter are handled here.
+func loop(cfg *config.Config, o *options, readClicks bool, emit func(row []blocks.Block, changed bool, providers []blocks.Provider) bool) {
+	// Build providers using registry + config order (held atomically with
+	// their config for live reloads), restricted to the active profile's modules.
+	var current atomic.Pointer[liveSet]
+	var (
+		swapMu        sync.Mutex
+		selector      = profile.NewSelector()
+		activeProfile string
+	)
+	// rebuild swaps in providers for newCfg under its active profile and
+	// closes the replaced ones; unless force is set (reloads) nothing happens
+	// while the profile is unchanged.
+	rebuild := func(newCfg *config.Config, force bool) {
+		swapMu.Lock()
+		name, manual := selector.Select(newCfg)
+		if !force && name == activeProfile {
+			swapMu.Unlock()
+			return
+		}
+		if name != activeProfile {
+			slog.Info("profile selected", "profile", name, "manual", manual)
+		}
+		activeProfile = name
+		old := current.Swap(&liveSet{cfg: newCfg, providers: blocks.BuildProviders(newCfg.WithProfile(name))})
+		swapMu.Unlock()
+		if old != nil {
+			// Stop the old providers' workers and plugin processes. Close
+			// can wait on a stuck worker, so it must not hold up the next
+			// rebuild or the caller.
+			go blocks.CloseProviders(old.providers)
+		}
+	}
+	rebuild(cfg, true)
+	liveCfg := func() *config.Config { return current.Load().cfg }
+	go func() {
+		for range time.Tick(profileCheckInterval) {
+			rebuild(liveCfg(), false)
+		}
+	}()
+
+	// Self-profiling: slow refreshes are logged; SIGUSR2 dumps all statistics.
+	stats.Default.SetSlow(time.Duration(cfg.Stats.SlowMs) * time.Millisecond)
+	usr2 := make(chan os.Signal, 1)
+	signal.Notify(usr2, syscall.SIGUSR2)
+	go func() {
+		for range usr2 {
+			stats.Default.Snapshot(time.Now()).Log()
+		}
+	}()
+
+	// Severity transitions are turned into desktop notifications off the render loop.
+	go notify.Run(events.Subscribe(32), liveCfg)
+
+	// The exporter serves the snapshot taken after each render; the listen
+	// address is read once at startup.
+	var exporter *metrics.Exporter
+	if cfg.Metrics.Listen != "" {
+		exporter = &metrics.Exporter{}
+		go func() {
+			if err := metrics.Serve(cfg.Metrics.Listen, exporter); err != nil {
+				slog.Error("metrics exporter stopped", "addr", cfg.Metrics.Listen, "err", err)
+			}
+		}()
+	}
+
+	// Only bars speaking the i3bar protocol send clicks; leave stdin alone otherwise.
 	clickCh := make(chan clicks.Click, 16)
-	go clicks.Read(os.Stdin, clickCh)
+	if readClicks {
+		go clicks.Read(os.Stdin, clickCh)
+	}
 
 	if cfg.TickHz < 1 {
 		cfg.TickHz = 1
@@ -44,135 +335,122 @@ func main() {
 	}
 	interval := time.Second / time.Duration(cfg.TickHz)
 
+	// Clicks are handled on this goroutine, between refreshes, so a provider
+	// never sees a click and a refresh at once. A provider loaded here may be
+	// closed concurrently after a rebuild; Close is safe to call meanwhile.
+	onClick := func(ev clicks.Click) bool {
+		return handleClick(ev, current.Load().providers)
+	}
+
 	// Initial alignment to next fractional interval boundary.
-	waitUntilNextTickInterval(interval, nil)
-
-	// After emitting the initial empty array, every subsequent row must be comma-prefixed per i3bar protocol.
-	// If we have a real config file, start watcher for automatic reloads.
-	if cfg.SourcePath != "" {
-		startConfigWatcher(cfg.SourcePath, func() {
-			newCfg, err := config.Load(cfg.SourcePath)
-			if err != nil {
-				log.Printf("config reload failed: %v", err)
-				return
-			}
-			providers.Store(blocks.BuildProviders(newCfg))
-			cfg = newCfg
-			log.Printf("config reloaded (%s)", cfg.SourcePath)
-		})
+	waitUntilNextTickInterval(interval, nil, nil)
+
+	// Reload automatically when any config file changes, or appears if
+	// there was none at startup.
+	watchPaths := func() []string {
+		return append(liveCfg().WatchPaths(), config.Candidates(o.configPath)...)
 	}
+	startConfigWatcher(watchPaths, func() {
+		newCfg, err := config.Load(o.configPath)
+		if err != nil {
+			slog.Error("config reload failed", "path", o.configPath, "err", err)
+			return
+		}
+		o.apply(newCfg)
+		stats.Default.SetSlow(time.Duration(newCfg.Stats.SlowMs) * time.Millisecond)
+		rebuild(newCfg, true)
+		slog.Info("config reloaded", "path", newCfg.SourcePath)
+	})
 
-	buf := bytes.NewBuffer(nil)
+	// Markup and show_when rules are applied after every refresh, to the full row.
+	markup := blocks.NewMarkup()
+	visibility := blocks.NewVisibility()
+	var last *liveSet
 	for {
-		drainClicks(clickCh)
-		current := providers.Load().([]blocks.Provider)
-		renderOnce(buf, current)
-		waitUntilNextTickInterval(interval, clickCh)
+		drainClicks(clickCh, onClick)
+		live := current.Load()
+		swapped := last != nil && live != last
+		if swapped {
+			// Reloaded (rebuild closed the old providers): drop their stats.
+			forgetStats(live.providers)
+		}
+		last = live
+		row, changed := refresh(live.providers)
+		markup.Apply(live.cfg, live.providers, row)
+		row, toggled := visibility.Filter(live.cfg, live.providers, row)
+		changed = changed || toggled || swapped
+		if (len(row) > 0 || changed) && emit(row, changed, live.providers) {
+			stats.Default.Row(time.Now())
+		}
+		if exporter != nil {
+			exporter.Set(blocks.CollectMetrics(live.providers))
+		}
+		waitUntilNextTickInterval(interval, clickCh, onClick)
 	}
 }
 
+// liveSet is a config and the providers built from it, swapped as one.
+type liveSet struct {
+	cfg       *config.Config
+	providers []blocks.Provider
+}
+
+// forgetStats keeps only the statistics of the given providers.
+func forgetStats(providers []blocks.Provider) {
+	running := map[[2]string]bool{}
+	for _, p := range providers {
+		running[[2]string{p.Name(), p.Current().Instance}] = true
+	}
+	stats.Default.Forget(func(module, instance string) bool { return running[[2]string{module, instance}] })
+}
+
 // drainClicks consumes all currently queued click events without blocking.
-func drainClicks(ch <-chan clicks.Click) {
+func drainClicks(ch <-chan clicks.Click, onClick func(clicks.Click) bool) {
 	for {
 		select {
 		case ev := <-ch:
-			handleClick(ev)
+			onClick(ev)
 		default:
 			return
 		}
 	}
 }
 
-// renderOnce refreshes providers (if due) and emits a JSON row.
-func renderOnce(buf *bytes.Buffer, providers []blocks.Provider) {
+// refresh lets providers refresh (if due) and returns the current row and
+// whether any block changed. At debug level every provider call is traced
+// with its duration.
+func refresh(providers []blocks.Provider) ([]blocks.Block, bool) {
 	nowNs := time.Now().UnixNano()
+	trace := slog.Default().Enabled(context.Background(), slog.LevelDebug)
 	changed := false
-	blocksOut := make([]blocks.Block, 0, len(providers))
+	row := make([]blocks.Block, 0, len(providers))
 	for _, p := range providers {
-		if p.MaybeRefresh(nowNs) {
-			changed = true
+		start := time.Now()
+		ch := p.MaybeRefresh(nowNs)
+		took := time.Since(start)
+		blk := p.Current()
+		if _, async := p.(*blocks.AsyncProvider); !async { // times its own worker
+			stats.Default.Observe(p.Name(), blk.Instance, took, ch)
 		}
-		blocksOut = append(blocksOut, p.Current())
-	}
-	if !changed && len(blocksOut) == 0 {
-		return
-	}
-	buf.Reset()
-	enc := json.NewEncoder(buf)
-	if err := enc.Encode(blocksOut); err != nil {
-		log.Printf("encode blocks: %v", err)
-		return
-	}
-	outBytes := bytes.TrimRight(buf.Bytes(), "\n")
-	fmt.Print(",")
-	fmt.Println(string(outBytes))
-}
-
-// startConfigWatcher watches a single file for WRITE/CHMOD events and invokes cb (debounced) on change.
-func startConfigWatcher(path string, cb func()) {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		log.Printf("config watcher init: %v", err)
-		return
-	}
-	parent := filepath.Dir(path)
-	if err := watcher.Add(parent); err != nil {
-		log.Printf("config watcher add: %v", err)
-		watcher.Close()
-		return
-	}
-	go func() {
-		defer watcher.Close()
-		var pending bool
-		var last time.Time
-		for {
-			select {
-			case ev, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				if !eventTargetsFile(ev, path) {
-					continue
-				}
-				// debounce ~150ms
-				if time.Since(last) < 150*time.Millisecond {
-					pending = true
-					continue
-				}
-				last = time.Now()
-				cb()
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
-				log.Printf("config watcher error: %v", err)
-			}
-			if pending && time.Since(last) >= 150*time.Millisecond {
-				pending = false
-				last = time.Now()
-				cb()
-			}
+		if trace {
+			slog.Debug("refresh", "module", p.Name(), "instance", blk.Instance, "took", took, "changed", ch)
 		}
-	}()
-}
-
-// eventTargetsFile checks if fsnotify event relates to the target file path.
-func eventTargetsFile(ev fsnotify.Event, target string) bool {
-	return ev.Name == target
+		changed = changed || ch
+		row = append(row, blk)
+	}
+	return row, changed
 }
 
-// dirName is a small helper (since path/filepath not imported here yet) - import path/filepath instead.
-// dirName helper removed (filepath.Dir used instead)
-
-func handleClick(c clicks.Click) {
-	// Placeholder: just log; future mapping to commands.
-	log.Printf("click: %+v", c)
+// handleClick routes a click to its provider; returns true if a block changed.
+func handleClick(c clicks.Click, providers []blocks.Provider) bool {
+	return blocks.DispatchClick(providers, c, time.Now().UnixNano())
 }
 
 // waitUntilNextTickInterval sleeps until the next multiple of interval boundary.
 // If clickCh is non-nil it will service a single click arrival without delaying
 // the boundary more than necessary (best-effort responsiveness between ticks).
-func waitUntilNextTickInterval(interval time.Duration, clickCh <-chan clicks.Click) {
+// It returns early when a click changed a block so the row can be re-rendered.
+func waitUntilNextTickInterval(interval time.Duration, clickCh <-chan clicks.Click, onClick func(clicks.Click) bool) {
 	now := time.Now()
 	// Compute next boundary: truncate to interval then add interval.
 	next := now.Truncate(interval).Add(interval)
@@ -194,7 +472,9 @@ func waitUntilNextTickInterval(interval time.Duration, clickCh <-chan clicks.Cli
 		if clickCh != nil {
 			select {
 			case ev := <-clickCh:
-				handleClick(ev)
+				if onClick(ev) {
+					return
+				}
 			default:
 			}
 		}
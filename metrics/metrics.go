@@ -0,0 +1,148 @@
+// Package metrics exports the latest provider samples in the Prometheus text
+// exposition format over HTTP, on a TCP address or a Unix socket.
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Type is the Prometheus metric type.
+type Type string
+
+const (
+	Gauge   Type = "gauge"
+	Counter Type = "counter"
+)
+
+// Label is one name="value" pair of a sample.
+type Label struct {
+	Name, Value string
+}
+
+// Sample is one exported value. Samples sharing a Name must share Help and Type.
+type Sample struct {
+	Name   string
+	Help   string
+	Type   Type
+	Labels []Label
+	Value  float64
+}
+
+// Exporter holds the most recent snapshot of samples and serves it.
+// Set is called from the render loop; ServeHTTP from the HTTP server.
+type Exporter struct {
+	mu      sync.RWMutex
+	samples []Sample
+}
+
+// Set replaces the exported snapshot.
+func (e *Exporter) Set(samples []Sample) {
+	e.mu.Lock()
+	e.samples = samples
+	e.mu.Unlock()
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.RLock()
+	samples := e.samples
+	e.mu.RUnlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	Write(bw, samples)
+	bw.Flush()
+}
+
+// Write renders samples in the text exposition format, grouped by name with
+// one HELP/TYPE header per metric family.
+func Write(w *bufio.Writer, samples []Sample) {
+	sorted := make([]Sample, len(samples))
+	copy(sorted, samples)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	last := ""
+	for _, s := range sorted {
+		if s.Name != last {
+			last = s.Name
+			if s.Help != "" {
+				fmt.Fprintf(w, "# HELP %s %s\n", s.Name, escapeHelp(s.Help))
+			}
+			fmt.Fprintf(w, "# TYPE %s %s\n", s.Name, s.Type)
+		}
+		w.WriteString(s.Name)
+		if len(s.Labels) > 0 {
+			w.WriteByte('{')
+			for i, l := range s.Labels {
+				if i > 0 {
+					w.WriteByte(',')
+				}
+				fmt.Fprintf(w, "%s=\"%s\"", l.Name, escapeLabel(l.Value))
+			}
+			w.WriteByte('}')
+		}
+		w.WriteByte(' ')
+		w.WriteString(strconv.FormatFloat(s.Value, 'g', -1, 64))
+		w.WriteByte('\n')
+	}
+}
+
+// Serve listens on addr ("host:port" or "unix:/path/to/socket") and serves
+// e at /metrics until the listener fails. A stale socket file is replaced;
+// a socket something still listens on, or any other file, is left alone and
+// the listen fails.
+func Serve(addr string, e *Exporter) error {
+	var (
+		ln  net.Listener
+		err error
+	)
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if err := removeStaleSocket(path); err != nil {
+			return err
+		}
+		ln, err = net.Listen("unix", path)
+	} else {
+		ln, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	slog.Info("metrics listening", "addr", addr)
+	return http.Serve(ln, mux)
+}
+
+// removeStaleSocket removes the socket at path if nothing accepts
+// connections on it, as after a crash. A live socket, e.g. another bar
+// serving the same address, is an error.
+func removeStaleSocket(path string) error {
+	if fi, err := os.Lstat(path); err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("listen unix %s: address in use", path)
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return os.Remove(path)
+	}
+	return nil // Listen reports the problem
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
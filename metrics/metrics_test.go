@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeKeepsRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.sock")
+	if err := os.WriteFile(path, []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Serve("unix:"+path, &Exporter{}); err == nil {
+		t.Fatal("Serve over a regular file succeeded")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "keep" {
+		t.Errorf("file = %q, %v; want it untouched", data, err)
+	}
+}
+
+func TestServeReplacesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+
+	e := &Exporter{}
+	e.Set([]Sample{{Name: "up", Type: Gauge, Value: 1}})
+	go Serve("unix:"+path, e)
+	var conn net.Conn
+	for range 50 {
+		if conn, err = net.Dial("unix", path); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET /metrics HTTP/1.0\r\n\r\n"))
+	var body strings.Builder
+	sc := bufio.NewScanner(conn)
+	for sc.Scan() {
+		body.WriteString(sc.Text() + "\n")
+	}
+	if !strings.Contains(body.String(), "\nup 1\n") {
+		t.Errorf("response = %q, want the sample", body.String())
+	}
+}
+
+func TestServeKeepsLiveSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	err = Serve("unix:"+path, &Exporter{})
+	if err == nil || !strings.Contains(err.Error(), "address in use") {
+		t.Fatalf("err = %v, want address in use", err)
+	}
+	if _, err := os.Lstat(path); err != nil {
+		t.Errorf("live socket removed: %v", err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var sb strings.Builder
+	w := bufio.NewWriter(&sb)
+	Write(w, []Sample{
+		{Name: "b", Help: "B.", Type: Gauge, Labels: []Label{{"block_instance", `a"b`}}, Value: 2},
+		{Name: "a", Type: Counter, Value: 1},
+		{Name: "b", Help: "B.", Type: Gauge, Labels: []Label{{"block_instance", "c"}}, Value: 0.5},
+	})
+	w.Flush()
+	want := "# TYPE a counter\na 1\n# HELP b B.\n# TYPE b gauge\nb{block_instance=\"a\\\"b\"} 2\nb{block_instance=\"c\"} 0.5\n"
+	if sb.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", sb.String(), want)
+	}
+}
@@ -0,0 +1,222 @@
+package migrate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// i3section is one [name] block of an i3blocks config, with the global
+// properties above the first section already applied.
+type i3section struct {
+	name  string
+	props map[string]string
+}
+
+func parseI3blocks(r io.Reader) ([]*i3section, error) {
+	global := map[string]string{}
+	var sections []*i3section
+	props := global
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		s := strings.TrimSpace(sc.Text())
+		switch {
+		case s == "" || s[0] == '#':
+		case s[0] == '[':
+			if !strings.HasSuffix(s, "]") {
+				return nil, fmt.Errorf("line %d: bad section %q", line, s)
+			}
+			sec := &i3section{name: strings.TrimSpace(s[1 : len(s)-1]), props: map[string]string{}}
+			for k, v := range global {
+				sec.props[k] = v
+			}
+			sections = append(sections, sec)
+			props = sec.props
+		default:
+			k, v, ok := strings.Cut(s, "=")
+			if !ok {
+				return nil, fmt.Errorf("line %d: want key=value, got %q", line, s)
+			}
+			props[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return sections, sc.Err()
+}
+
+// varRef matches $NAME, ${NAME} and ${NAME:-default} in a command.
+var varRef = regexp.MustCompile(`\$(?:\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}|([A-Za-z_][A-Za-z0-9_]*))`)
+
+// dateCommand matches `date +FORMAT` with an optionally quoted format.
+var dateCommand = regexp.MustCompile(`^date\s+(?:'\+([^']*)'|"\+([^"]*)"|\+(\S+))$`)
+
+func fromI3blocks(r io.Reader) (*doc, error) {
+	sections, err := parseI3blocks(r)
+	if err != nil {
+		return nil, err
+	}
+	d := &doc{from: "i3blocks"}
+	for _, sec := range sections {
+		d.i3section(sec)
+	}
+	return d, nil
+}
+
+func (d *doc) i3section(sec *i3section) {
+	p := sec.props
+	label := strings.TrimSpace(p["label"])
+	command := expandCommand(p["command"], sec)
+	if strings.TrimSpace(p["command"]) == "" {
+		if text, ok := p["full_text"]; ok {
+			m := d.add("exec", sec.name)
+			m.note("[%s]: static text", sec.name)
+			m.set("command", "echo "+shellQuote(text))
+			m.set("interval_sec", 0)
+			return
+		}
+		d.skip("[%s]: no command; not imported", sec.name)
+		return
+	}
+
+	// Blocks from i3blocks-contrib (or date) with a built-in counterpart.
+	instance := p["instance"]
+	switch script := filepath.Base(command); {
+	case script == "cpu_usage":
+		m := d.add("cpu", sec.name)
+		setPrefix(m, label)
+		d.interval(m, p["interval"])
+		return
+	case script == "memory" && instance != "swap":
+		m := d.add("mem", sec.name)
+		setPrefix(m, label)
+		m.set("format", "available")
+		d.interval(m, p["interval"])
+		return
+	case script == "disk":
+		m := d.add("disk", sec.name)
+		setPrefix(m, label)
+		m.set("path", propOr(p, "instance", "~/"))
+		m.set("format", "available")
+		d.interval(m, p["interval"])
+		return
+	case dateCommand.MatchString(command):
+		sub := dateCommand.FindStringSubmatch(command)
+		m := d.add("time", sec.name)
+		m.set("format", label+sub[1]+sub[2]+sub[3])
+		return
+	}
+
+	m := d.add("exec", sec.name)
+	setPrefix(m, label)
+	m.set("command", literal(p["command"]))
+	if instance != "" {
+		m.set("block_instance", literal(instance))
+	}
+	if env := blockEnv(sec); len(env) > 0 {
+		m.set("env", env)
+	}
+	d.interval(m, p["interval"])
+	if n, ok := p["signal"]; ok {
+		m.note("[%s]: signal %s is not supported; the block refreshes on its interval and on clicks", sec.name, n)
+	}
+	if p["format"] == "json" {
+		m.note("[%s]: format=json output is not supported; the command must print lines", sec.name)
+	}
+	if c, ok := p["color"]; ok {
+		m.note("[%s]: static color %s not imported; print it as the third line instead", sec.name, c)
+	}
+}
+
+// interval translates an i3blocks interval (seconds, once, repeat, persist).
+func (d *doc) interval(m *module, v string) {
+	switch v {
+	case "", "once":
+		if m.kind == "exec" {
+			m.set("interval_sec", 0)
+		}
+	case "repeat", "persist":
+		m.set("interval_sec", 1)
+		m.note("interval=%s: the command is polled every second instead of running continuously", v)
+	default:
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			m.set("interval_sec", n)
+		}
+	}
+}
+
+// i3blocksKeys are the properties i3blocks itself interprets; blockEnv
+// exports the others, which scripts read as settings.
+var i3blocksKeys = map[string]bool{
+	"command": true, "instance": true, "label": true, "interval": true, "signal": true,
+	"format": true, "color": true, "background": true, "border": true, "full_text": true,
+	"short_text": true, "min_width": true, "align": true, "urgent": true, "markup": true,
+	"separator": true, "separator_block_width": true, "name": true,
+	"border_top": true, "border_right": true, "border_bottom": true, "border_left": true,
+}
+
+// blockEnv returns the environment i3blocks would give the section's command
+// beyond BLOCK_INSTANCE: its own properties and, if the command refers to it,
+// BLOCK_NAME as the section name (the swaystats instance may be renamed).
+func blockEnv(sec *i3section) []string {
+	var env []string
+	if strings.Contains(sec.props["command"], "BLOCK_NAME") {
+		env = append(env, "BLOCK_NAME="+literal(sec.name))
+	}
+	for _, k := range slices.Sorted(maps.Keys(sec.props)) {
+		if !i3blocksKeys[k] {
+			env = append(env, k+"="+literal(sec.props[k]))
+		}
+	}
+	return env
+}
+
+// literal escapes ${...} in s, which swaystats would otherwise expand when
+// loading the config.
+func literal(s string) string {
+	return strings.ReplaceAll(s, "${", "$${")
+}
+
+// expandCommand substitutes the section's properties ($BLOCK_NAME,
+// $BLOCK_INSTANCE and any other i3blocks property or global) into cmd, so
+// commands such as /usr/share/i3blocks/$BLOCK_NAME can be recognized. The
+// result is only matched against, never written: exec blocks get the
+// properties in their environment instead.
+func expandCommand(cmd string, sec *i3section) string {
+	vars := map[string]string{"BLOCK_NAME": sec.name, "BLOCK_INSTANCE": sec.props["instance"]}
+	for k, v := range sec.props {
+		if k != "command" {
+			vars[k] = v
+		}
+	}
+	return varRef.ReplaceAllStringFunc(cmd, func(ref string) string {
+		m := varRef.FindStringSubmatch(ref)
+		name, braced := m[1]+m[3], m[1] != ""
+		if v, ok := vars[name]; ok {
+			if v == "" && braced {
+				return m[2]
+			}
+			return v
+		}
+		if braced {
+			return "$" + ref
+		}
+		return ref
+	})
+}
+
+// setPrefix sets the i3blocks label as prefix, unless it is empty.
+func setPrefix(m *module, label string) {
+	if label != "" {
+		m.set("prefix", label)
+	}
+}
+
+// shellQuote quotes s for sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
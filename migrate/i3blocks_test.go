@@ -0,0 +1,207 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+)
+
+const i3blocksFull = `# globals
+command=/usr/share/i3blocks/$BLOCK_NAME
+separator_block_width=15
+interval=5
+
+[cpu_usage]
+label=CPU
+
+[memory]
+label=MEM
+
+[memory]
+instance=swap
+label=SWAP
+
+[disk]
+instance=/home
+interval=30
+
+[volume]
+command=~/bin/vol ${BLOCK_INSTANCE:-Master} $HOME ${UNSET}
+instance=
+signal=10
+interval=once
+color=#ff0000
+
+[mediaplayer]
+interval=persist
+format=json
+
+[time]
+command=date '+%H:%M'
+label=T
+
+[greeting]
+full_text=it's me
+command=
+
+[empty]
+command=
+`
+
+func TestParseI3blocks(t *testing.T) {
+	sections, err := parseI3blocks(strings.NewReader(i3blocksFull))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 9 {
+		t.Fatalf("got %d sections, want 9", len(sections))
+	}
+	disk := sections[3]
+	if disk.name != "disk" || disk.props["instance"] != "/home" || disk.props["interval"] != "30" {
+		t.Errorf("disk = %+v", disk)
+	}
+	if got := disk.props["separator_block_width"]; got != "15" {
+		t.Errorf("global not applied: separator_block_width = %q", got)
+	}
+	if got := sections[0].props["command"]; got != "/usr/share/i3blocks/$BLOCK_NAME" {
+		t.Errorf("global command = %q", got)
+	}
+}
+
+func TestParseI3blocksErrors(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"[open\n", "line 1: bad section"},
+		{"[a]\nlabel\n", "line 2: want key=value"},
+	}
+	for _, tt := range tests {
+		_, err := parseI3blocks(strings.NewReader(tt.src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: err = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestExpandCommand(t *testing.T) {
+	sec := &i3section{name: "vol", props: map[string]string{"instance": "Master", "step": "5%", "empty": "", "command": "x"}}
+	tests := []struct{ cmd, want string }{
+		{"amixer get $BLOCK_INSTANCE", "amixer get Master"},
+		{"run ${BLOCK_NAME}", "run vol"},
+		{"up $step", "up 5%"},
+		{"${empty:-fallback} ${step:-1%}", "fallback 5%"},
+		{"echo $HOME", "echo $HOME"},
+		{"echo ${HOME}", "echo $${HOME}"},
+		{"echo ${NOPE:-x}", "echo $${NOPE:-x}"},
+		{"echo $command", "echo $command"},
+		{"cost $5", "cost $5"},
+	}
+	for _, tt := range tests {
+		if got := expandCommand(tt.cmd, sec); got != tt.want {
+			t.Errorf("expandCommand(%q) = %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"hi", "'hi'"},
+		{"it's", `'it'\''s'`},
+		{"$HOME", "'$HOME'"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFromI3blocks(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"full", i3blocksFull, `
+[modules.cpu]
+prefix = "CPU"
+interval_sec = 5
+
+[modules.mem]
+prefix = "MEM"
+format = "available"
+interval_sec = 5
+
+[modules.exec.memory]
+prefix = "SWAP"
+command = "/usr/share/i3blocks/$BLOCK_NAME"
+block_instance = "swap"
+env = ["BLOCK_NAME=memory"]
+interval_sec = 5
+
+[modules.disk]
+path = "/home"
+format = "available"
+interval_sec = 30
+
+# [volume]: signal 10 is not supported; the block refreshes on its interval and on clicks
+# [volume]: static color #ff0000 not imported; print it as the third line instead
+[modules.exec.volume]
+command = "~/bin/vol $${BLOCK_INSTANCE:-Master} $HOME $${UNSET}"
+interval_sec = 0
+
+# interval=persist: the command is polled every second instead of running continuously
+# [mediaplayer]: format=json output is not supported; the command must print lines
+[modules.exec.mediaplayer]
+command = "/usr/share/i3blocks/$BLOCK_NAME"
+env = ["BLOCK_NAME=mediaplayer"]
+interval_sec = 1
+
+[modules.time]
+format = "T%H:%M"
+
+# [greeting]: static text
+[modules.exec.greeting]
+command = "echo 'it'\\''s me'"
+interval_sec = 0
+
+# [empty]: no command; not imported
+`},
+		{"date formats", "[a]\ncommand=date +%H:%M\n[b]\ncommand=date \"+%d.%m\"\n[c]\ncommand=date +%s && true\n", `
+[modules.time.a]
+format = "%H:%M"
+
+[modules.time.b]
+format = "%d.%m"
+
+[modules.exec.c]
+command = "date +%s && true"
+interval_sec = 0
+`},
+		{"disk without instance", "[disk]\ncommand=/usr/lib/i3blocks/disk\ninterval=abc\n", `
+[modules.disk]
+path = "~/"
+format = "available"
+`},
+		{"properties stay out of the command", "[bandwidth]\ncommand=bw $BLOCK_INSTANCE $IFACE\ninstance=eth0; rm -rf ~\nIFACE=$(reboot) ${X}\nlabel=BW\n", `
+[modules.exec.bandwidth]
+prefix = "BW"
+command = "bw $BLOCK_INSTANCE $IFACE"
+block_instance = "eth0; rm -rf ~"
+env = ["IFACE=$(reboot) $${X}"]
+interval_sec = 0
+`},
+		{"repeated names", "[x]\ncommand=a\ninterval=10\n[x]\ncommand=b\ninterval=repeat\n", `
+[modules.exec.x]
+command = "a"
+interval_sec = 10
+
+# interval=repeat: the command is polled every second instead of running continuously
+[modules.exec.x_2]
+command = "b"
+interval_sec = 1
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := importBody(t, "i3blocks", tt.src); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
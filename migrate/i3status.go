@@ -0,0 +1,322 @@
+package migrate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// i3block is one `name [instance] { key = value ... }` section of an
+// i3status config.
+type i3block struct {
+	name, instance string
+	props          map[string]string
+}
+
+// parseI3status reads the order list and the sections of an i3status config.
+func parseI3status(r io.Reader) (order []string, blocks []*i3block, err error) {
+	toks, err := lexI3status(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := 0; i < len(toks); {
+		t := toks[i]
+		switch {
+		case t.text == "order" && i+2 < len(toks) && toks[i+1].op == "+=":
+			order = append(order, toks[i+2].text)
+			i += 3
+		case i+1 < len(toks) && toks[i+1].op == "=": // top-level setting (unused by i3status)
+			i += 3
+		case t.op == "":
+			b := &i3block{name: t.text, props: map[string]string{}}
+			i++
+			if i < len(toks) && toks[i].op == "" {
+				b.instance = toks[i].text
+				i++
+			}
+			if i >= len(toks) || toks[i].op != "{" {
+				return nil, nil, fmt.Errorf("line %d: want { after %q", t.line, t.text)
+			}
+			for i++; i < len(toks) && toks[i].op != "}"; i += 3 {
+				if i+2 >= len(toks) || toks[i+1].op != "=" {
+					return nil, nil, fmt.Errorf("line %d: want key = value in %q", toks[i].line, b.name)
+				}
+				b.props[toks[i].text] = toks[i+2].text
+			}
+			if i >= len(toks) {
+				return nil, nil, fmt.Errorf("line %d: unterminated section %q", t.line, t.text)
+			}
+			i++ // }
+			blocks = append(blocks, b)
+		default:
+			return nil, nil, fmt.Errorf("line %d: unexpected %q", t.line, t.op)
+		}
+	}
+	return order, blocks, nil
+}
+
+type i3token struct {
+	text string // word or string contents
+	op   string // "{", "}", "=" or "+="; empty for words and strings
+	line int
+}
+
+func lexI3status(r io.Reader) ([]i3token, error) {
+	var toks []i3token
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		s := sc.Text()
+		for i := 0; i < len(s); {
+			switch c := s[i]; {
+			case c == ' ' || c == '\t' || c == '\r':
+				i++
+			case c == '#':
+				i = len(s)
+			case c == '{' || c == '}' || c == '=':
+				toks = append(toks, i3token{op: string(c), line: line})
+				i++
+			case strings.HasPrefix(s[i:], "+="):
+				toks = append(toks, i3token{op: "+=", line: line})
+				i += 2
+			case c == '"' || c == '\'':
+				var sb strings.Builder
+				j := i + 1
+				for ; j < len(s) && s[j] != c; j++ {
+					if s[j] == '\\' && j+1 < len(s) {
+						j++
+					}
+					sb.WriteByte(s[j])
+				}
+				if j >= len(s) {
+					return nil, fmt.Errorf("line %d: unterminated string", line)
+				}
+				toks = append(toks, i3token{text: sb.String(), line: line})
+				i = j + 1
+			default:
+				j := i
+				for j < len(s) && !strings.ContainsRune(" \t\r{}=\"'#", rune(s[j])) && !strings.HasPrefix(s[j:], "+=") {
+					j++
+				}
+				toks = append(toks, i3token{text: s[i:j], line: line})
+				i = j
+			}
+		}
+	}
+	return toks, sc.Err()
+}
+
+func fromI3status(r io.Reader) (*doc, error) {
+	order, blocks, err := parseI3status(r)
+	if err != nil {
+		return nil, err
+	}
+	d := &doc{from: "i3status"}
+	byName := map[string]*i3block{}
+	general := &i3block{props: map[string]string{}}
+	for _, b := range blocks {
+		if b.name == "general" {
+			general = b
+			continue
+		}
+		byName[strings.TrimSpace(b.name+" "+b.instance)] = b
+	}
+	if len(order) == 0 { // no order list: keep the sections as written
+		for _, b := range blocks {
+			if b.name != "general" {
+				order = append(order, b.name+" "+b.instance)
+			}
+		}
+	}
+	d.general(general.props)
+	for _, entry := range order {
+		entry = strings.TrimSpace(entry)
+		name, instance, _ := strings.Cut(entry, " ")
+		b := byName[entry]
+		if b == nil {
+			b = &i3block{name: name, instance: strings.TrimSpace(instance), props: map[string]string{}}
+		}
+		switch name {
+		case "cpu_usage":
+			d.cpuUsage(b)
+		case "memory":
+			d.memory(b)
+		case "tztime", "time":
+			d.tztime(b)
+		case "disk":
+			d.disk(b)
+		case "battery":
+			d.battery(b)
+		default:
+			d.skip("%s: no swaystats module; not imported", entry)
+		}
+		if m := d.modules[len(d.modules)-1]; m.kind != "" && m.kind != "time" && m.kind != "exec" {
+			if v, ok := general.props["interval"]; ok {
+				if n, err := strconv.Atoi(v); err == nil && n > 0 {
+					m.set("interval_sec", n)
+				}
+			}
+		}
+	}
+	return d, nil
+}
+
+// general maps the output settings of the general section.
+func (d *doc) general(p map[string]string) {
+	switch f := p["output_format"]; f {
+	case "":
+	case "i3bar", "lemonbar":
+		d.output = append(d.output, kv{"format", f})
+	case "term", "none":
+		d.output = append(d.output, kv{"format", "plain"})
+	default:
+		d.skip("general: output_format %q has no swaystats equivalent", f)
+	}
+	if sep, ok := p["separator"]; ok {
+		d.output = append(d.output, kv{"separator", sep})
+	}
+}
+
+func (d *doc) cpuUsage(b *i3block) {
+	m := d.add("cpu", b.instance)
+	prefix, rest := splitFormat(propOr(b.props, "format", "%usage"))
+	if prefix != "" {
+		m.set("prefix", prefix)
+	}
+	if rest != "%usage" {
+		m.note("cpu_usage: format %q shortened to the total usage", b.props["format"])
+	}
+	if n, ok := percent(b.props["degraded_threshold"]); ok {
+		m.set("warn_percent", n)
+	}
+	if n, ok := percent(b.props["max_threshold"]); ok {
+		m.set("danger_percent", n)
+	}
+}
+
+func (d *doc) memory(b *i3block) {
+	m := d.add("mem", b.instance)
+	format := propOr(b.props, "format", "%used / %total")
+	prefix, rest := splitFormat(format)
+	if prefix != "" {
+		m.set("prefix", prefix)
+	}
+	switch {
+	case strings.HasPrefix(rest, "%percentage_used"):
+		m.set("format", "percent")
+	case strings.HasPrefix(rest, "%percentage_"):
+		m.set("format", "percent")
+		m.note("memory: format %q shows free memory; swaystats shows the used percentage", format)
+	case strings.HasPrefix(rest, "%used"):
+		m.set("format", "used")
+	case strings.HasPrefix(rest, "%available"), strings.HasPrefix(rest, "%free"):
+		m.set("format", "available")
+	default:
+		m.note("memory: format %q not translated", format)
+	}
+	// i3status thresholds are on available memory, swaystats ones on usage.
+	for _, t := range [][2]string{{"threshold_degraded", "warn_percent"}, {"threshold_critical", "danger_percent"}} {
+		v, ok := b.props[t[0]]
+		if !ok {
+			continue
+		}
+		if n, ok := percent(v); ok && strings.HasSuffix(v, "%") {
+			m.set(t[1], 100-n)
+		} else {
+			m.note("memory: %s = %q is absolute; only percentages are imported", t[0], v)
+		}
+	}
+}
+
+func (d *doc) tztime(b *i3block) {
+	m := d.add("time", b.instance)
+	format := propOr(b.props, "format", "%Y-%m-%d %H:%M:%S %Z")
+	if ft, ok := b.props["format_time"]; ok {
+		format = strings.ReplaceAll(format, "%time", ft)
+	}
+	m.set("format", format)
+	if tz, ok := b.props["timezone"]; ok {
+		m.set("timezone", tz)
+	}
+	if loc, ok := b.props["locale"]; ok {
+		m.set("locale", loc)
+	}
+	if _, ok := b.props["hide_if_equals_localtime"]; ok {
+		m.note("%s %s: hide_if_equals_localtime is not supported", b.name, b.instance)
+	}
+}
+
+func (d *doc) disk(b *i3block) {
+	m := d.add("disk", b.instance)
+	path := b.instance
+	if path == "" {
+		path = "/"
+	}
+	m.set("path", path)
+	format := propOr(b.props, "format", "%free")
+	prefix, rest := splitFormat(format)
+	if prefix != "" {
+		m.set("prefix", prefix)
+	}
+	switch {
+	case strings.HasPrefix(rest, "%percentage_used"):
+		m.set("format", "percent")
+	case strings.HasPrefix(rest, "%percentage_"):
+		m.set("format", "percent")
+		m.note("disk %s: format %q shows free space; swaystats shows the used percentage", b.instance, format)
+	case strings.HasPrefix(rest, "%used"):
+		m.set("format", "used")
+	case strings.HasPrefix(rest, "%free"), strings.HasPrefix(rest, "%avail"):
+		m.set("format", "available")
+	default:
+		m.note("disk %s: format %q not translated", b.instance, format)
+	}
+	if v, ok := b.props["low_threshold"]; ok {
+		switch t := propOr(b.props, "threshold_type", "percentage_avail"); t {
+		case "percentage_avail", "percentage_free":
+			if n, ok := percent(v); ok {
+				m.set("danger_percent", 100-n)
+			}
+		default:
+			m.note("disk %s: low_threshold with threshold_type %q not imported", b.instance, t)
+		}
+	}
+}
+
+// battery becomes an exec block reading sysfs, as swaystats has no battery
+// module; it turns urgent below low_threshold percent while discharging.
+func (d *doc) battery(b *i3block) {
+	m := d.add("exec", "battery")
+	if b.instance != "0" && b.instance != "" && b.instance != "all" {
+		m.name = "battery_" + b.instance
+	}
+	dir := "/sys/class/power_supply/BAT" + b.instance
+	if b.instance == "all" {
+		dir = "/sys/class/power_supply/BAT0"
+		m.note("battery all: only the first battery is shown")
+	}
+	if p, ok := b.props["path"]; ok {
+		dir = filepath.Dir(strings.ReplaceAll(p, "%d", b.instance))
+	}
+	low := 10
+	if n, ok := percent(b.props["low_threshold"]); ok {
+		low = n
+	}
+	m.note("battery %s: swaystats has no battery module; this exec block reads %s", b.instance, dir)
+	prefix, _ := splitFormat(b.props["format"])
+	if prefix == "" {
+		prefix = "BAT"
+	}
+	m.set("prefix", prefix)
+	m.set("command", fmt.Sprintf(`d=%s; c=$(cat $d/capacity) && s=$(cat $d/status) || exit 1; echo "$c%% $s"; [ "$c" -gt %d ] || [ "$s" != Discharging ] || exit 33`, dir, low))
+	m.set("interval_sec", 30)
+}
+
+func propOr(p map[string]string, key, fallback string) string {
+	if v, ok := p[key]; ok && v != "" {
+		return v
+	}
+	return fallback
+}
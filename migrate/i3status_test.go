@@ -0,0 +1,196 @@
+package migrate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const i3statusFull = `# i3status configuration
+general {
+  output_format = "i3bar"
+  interval = 5
+  separator = " | "
+}
+
+order += "cpu_usage"
+order += "disk /"
+order += "disk /home"
+order += "memory"
+order += "wireless _first_"
+order += "battery 0"
+order += "tztime local"
+
+cpu_usage { format = "CPU %usage" degraded_threshold = 70 max_threshold = "90%" }
+disk "/" { format = "/ %percentage_used" low_threshold = 10 }
+disk "/home" {
+  format = "H %avail"
+}
+memory {
+  format = "%used"
+  threshold_degraded = "20%"
+  threshold_critical = "1G"
+}
+battery 0 { format = "B %percentage" low_threshold = 15 }
+tztime local {
+  format = "%time"
+  format_time = "%H:%M"
+  timezone = "Europe/Berlin"
+}
+`
+
+func TestParseI3status(t *testing.T) {
+	order, blocks, err := parseI3status(strings.NewReader(i3statusFull))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantOrder := []string{"cpu_usage", "disk /", "disk /home", "memory", "wireless _first_", "battery 0", "tztime local"}
+	if !reflect.DeepEqual(order, wantOrder) {
+		t.Errorf("order = %q, want %q", order, wantOrder)
+	}
+	var got []string
+	for _, b := range blocks {
+		got = append(got, b.name+"|"+b.instance)
+	}
+	wantBlocks := []string{"general|", "cpu_usage|", "disk|/", "disk|/home", "memory|", "battery|0", "tztime|local"}
+	if !reflect.DeepEqual(got, wantBlocks) {
+		t.Errorf("blocks = %q, want %q", got, wantBlocks)
+	}
+	if p := blocks[1].props; p["format"] != "CPU %usage" || p["degraded_threshold"] != "70" || p["max_threshold"] != "90%" {
+		t.Errorf("cpu_usage props = %v", p)
+	}
+}
+
+func TestLexI3status(t *testing.T) {
+	toks, err := lexI3status(strings.NewReader("a+=\"x \\\"y\\\"\" # comment\nb 'c d'{k=v}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tok := range toks {
+		got = append(got, tok.text+tok.op)
+	}
+	want := []string{"a", "+=", `x "y"`, "b", "c d", "{", "k", "=", "v", "}"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens = %q, want %q", got, want)
+	}
+	if toks[3].line != 2 {
+		t.Errorf("line of b = %d, want 2", toks[3].line)
+	}
+}
+
+func TestParseI3statusErrors(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{`disk "/" format = "x"`, "line 1: want {"},
+		{"memory {\n  format\n}", "line 2: want key = value"},
+		{"memory {\n  format = x\n", "line 1: unterminated section"},
+		{"}", `unexpected "}"`},
+		{`order += "disk /`, "unterminated string"},
+	}
+	for _, tt := range tests {
+		_, _, err := parseI3status(strings.NewReader(tt.src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: err = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestFromI3status(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"full", i3statusFull, `
+[output]
+format = "i3bar"
+separator = " | "
+
+[modules.cpu]
+prefix = "CPU"
+warn_percent = 70
+danger_percent = 90
+interval_sec = 5
+
+[modules.disk.root]
+path = "/"
+prefix = "/"
+format = "percent"
+danger_percent = 90
+interval_sec = 5
+
+[modules.disk.home]
+path = "/home"
+prefix = "H"
+format = "available"
+interval_sec = 5
+
+# memory: threshold_critical = "1G" is absolute; only percentages are imported
+[modules.mem]
+format = "used"
+warn_percent = 80
+interval_sec = 5
+
+# wireless _first_: no swaystats module; not imported
+
+# battery 0: swaystats has no battery module; this exec block reads /sys/class/power_supply/BAT0
+[modules.exec.battery]
+prefix = "B"
+command = "d=/sys/class/power_supply/BAT0; c=$(cat $d/capacity) && s=$(cat $d/status) || exit 1; echo \"$c% $s\"; [ \"$c\" -gt 15 ] || [ \"$s\" != Discharging ] || exit 33"
+interval_sec = 30
+
+[modules.time]
+format = "%H:%M"
+timezone = "Europe/Berlin"
+`},
+		{"no order keeps sections", "memory { format = \"%percentage_free\" }\ncpu_usage {}\n", `
+# memory: format "%percentage_free" shows free memory; swaystats shows the used percentage
+[modules.mem]
+format = "percent"
+
+[modules.cpu]
+`},
+		{"order without sections", "order += \"disk /\"\norder += \"time\"\n", `
+[modules.disk]
+path = "/"
+format = "available"
+
+[modules.time]
+format = "%Y-%m-%d %H:%M:%S %Z"
+`},
+		{"output formats", "general { output_format = \"term\" }\ngeneral { output_format = \"xmobar\" }\n", `
+# general: output_format "xmobar" has no swaystats equivalent
+`},
+		{"disk thresholds", "disk \"/var\" { format = \"%foo\" low_threshold = 5 threshold_type = \"gbytes_avail\" }\n", `
+# disk /var: format "%foo" not translated
+# disk /var: low_threshold with threshold_type "gbytes_avail" not imported
+[modules.disk]
+path = "/var"
+`},
+		{"tztime options", "tztime berlin { locale = \"de_DE\" hide_if_equals_localtime = true }\n", `
+# tztime berlin: hide_if_equals_localtime is not supported
+[modules.time]
+format = "%Y-%m-%d %H:%M:%S %Z"
+locale = "de_DE"
+`},
+		{"battery path and instance", "battery 1 { path = \"/sys/class/power_supply/CMB%d/uevent\" }\nbattery all {}\n", `
+# battery 1: swaystats has no battery module; this exec block reads /sys/class/power_supply/CMB1
+[modules.exec.battery_1]
+prefix = "BAT"
+command = "d=/sys/class/power_supply/CMB1; c=$(cat $d/capacity) && s=$(cat $d/status) || exit 1; echo \"$c% $s\"; [ \"$c\" -gt 10 ] || [ \"$s\" != Discharging ] || exit 33"
+interval_sec = 30
+
+# battery all: only the first battery is shown
+# battery all: swaystats has no battery module; this exec block reads /sys/class/power_supply/BAT0
+[modules.exec.battery]
+prefix = "BAT"
+command = "d=/sys/class/power_supply/BAT0; c=$(cat $d/capacity) && s=$(cat $d/status) || exit 1; echo \"$c% $s\"; [ \"$c\" -gt 10 ] || [ \"$s\" != Discharging ] || exit 33"
+interval_sec = 30
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := importBody(t, "i3status", tt.src); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
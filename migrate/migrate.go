@@ -0,0 +1,176 @@
+// Package migrate turns i3status and i3blocks configurations into an
+// equivalent swaystats config (TOML). Modules with a swaystats counterpart
+// are translated setting by setting; i3blocks commands become exec blocks;
+// anything else is listed as a comment so nothing disappears silently.
+package migrate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Formats lists the formats Import understands.
+var Formats = []string{"i3status", "i3blocks"}
+
+// Import reads a config in format from r and writes swaystats TOML to w.
+func Import(w io.Writer, r io.Reader, format string) error {
+	var d *doc
+	var err error
+	switch format {
+	case "i3status":
+		d, err = fromI3status(r)
+	case "i3blocks":
+		d, err = fromI3blocks(r)
+	default:
+		return fmt.Errorf("unknown format %q (want one of %s)", format, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return err
+	}
+	_, err = d.WriteTo(w)
+	return err
+}
+
+// doc is the swaystats config being built: global settings plus module
+// tables (or notes about what could not be translated) in bar order.
+type doc struct {
+	from    string
+	output  []kv
+	modules []*module
+}
+
+type kv struct {
+	key string
+	val any
+}
+
+// module is one module instance. A module without kind only carries notes.
+type module struct {
+	kind, name string // name picks the instance; empty names are numbered if needed
+	values     []kv
+	notes      []string
+}
+
+func (m *module) set(key string, val any) { m.values = append(m.values, kv{key, val}) }
+
+func (m *module) note(format string, args ...any) {
+	m.notes = append(m.notes, fmt.Sprintf(format, args...))
+}
+
+func (d *doc) add(kind, name string) *module {
+	m := &module{kind: kind, name: name}
+	d.modules = append(d.modules, m)
+	return m
+}
+
+// skip records a module that has no swaystats equivalent.
+func (d *doc) skip(format string, args ...any) {
+	m := d.add("", "")
+	m.note(format, args...)
+}
+
+// WriteTo writes the document as TOML. A kind used once is written as its
+// base table ([modules.cpu]); kinds used several times get named instances
+// ([modules.disk.home]), so every module keeps its position.
+func (d *doc) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Imported from %s by `swaystats import`. Check the notes below.\n", d.from)
+	if len(d.output) > 0 {
+		buf.WriteString("\n[output]\n")
+		writeValues(&buf, d.output)
+	}
+	count := map[string]int{}
+	for _, m := range d.modules {
+		if m.kind != "" {
+			count[m.kind]++
+		}
+	}
+	used := map[string]bool{}
+	for _, m := range d.modules {
+		buf.WriteString("\n")
+		for _, n := range m.notes {
+			buf.WriteString("# " + n + "\n")
+		}
+		if m.kind == "" {
+			continue
+		}
+		header := "modules." + m.kind
+		if count[m.kind] > 1 || m.kind == "exec" {
+			base := instanceName(m.name)
+			if base == "" {
+				base = m.kind
+			}
+			name := base
+			for n := 2; used[m.kind+"."+name]; n++ {
+				name = fmt.Sprintf("%s_%d", base, n)
+			}
+			used[m.kind+"."+name] = true
+			header += "." + name
+		}
+		fmt.Fprintf(&buf, "[%s]\n", header)
+		writeValues(&buf, m.values)
+	}
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+func writeValues(buf *bytes.Buffer, values []kv) {
+	for _, v := range values {
+		fmt.Fprintf(buf, "%s = %s\n", v.key, tomlValue(v.val))
+	}
+}
+
+func tomlValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		// JSON string escapes are a subset of TOML basic string escapes.
+		var b bytes.Buffer
+		enc := json.NewEncoder(&b)
+		enc.SetEscapeHTML(false)
+		enc.Encode(v)
+		return strings.TrimSuffix(b.String(), "\n")
+	case []string:
+		parts := make([]string, len(v))
+		for i, s := range v {
+			parts[i] = tomlValue(s)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(v)
+}
+
+var nonName = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// instanceName turns an i3 instance ("/home", "Europe/Berlin") into a bare
+// TOML key ("home", "Europe_Berlin"); "/" becomes "root".
+func instanceName(s string) string {
+	if s == "/" {
+		return "root"
+	}
+	return strings.Trim(nonName.ReplaceAllString(s, "_"), "_")
+}
+
+// splitFormat splits an i3 format string at its first placeholder ("%used",
+// "%Y") into the literal text before it, used as prefix, and the rest.
+func splitFormat(format string) (prefix, rest string) {
+	i := strings.IndexByte(format, '%')
+	if i < 0 {
+		return format, ""
+	}
+	return strings.TrimSpace(format[:i]), format[i:]
+}
+
+// percent parses an integer percentage ("90", "90%").
+func percent(s string) (int, bool) {
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(s, "%")))
+	return n, err == nil && n >= 0 && n <= 100
+}
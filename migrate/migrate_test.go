@@ -0,0 +1,154 @@
+package migrate
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"swaystats/config"
+)
+
+// importBody imports src and returns the output without its title line.
+func importBody(t *testing.T, format, src string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Import(&buf, strings.NewReader(src), format); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	_, body, _ := strings.Cut(buf.String(), "\n")
+	return body
+}
+
+func TestImportUnknownFormat(t *testing.T) {
+	if err := Import(&bytes.Buffer{}, strings.NewReader(""), "conky"); err == nil {
+		t.Error("import from conky succeeded")
+	}
+}
+
+// TestImportLoads checks that imported configs load without errors.
+func TestImportLoads(t *testing.T) {
+	for _, tt := range []struct{ format, src string }{
+		{"i3status", i3statusFull},
+		{"i3blocks", i3blocksFull},
+	} {
+		var buf bytes.Buffer
+		if err := Import(&buf, strings.NewReader(tt.src), tt.format); err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := config.Load(path)
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", tt.format, err, buf.String())
+		}
+		if len(cfg.ModuleOrder()) == 0 {
+			t.Errorf("%s: no modules", tt.format)
+		}
+	}
+}
+
+func TestWriteToInstances(t *testing.T) {
+	d := &doc{from: "test"}
+	d.add("cpu", "")
+	d.add("disk", "/")
+	d.add("disk", "/home")
+	d.add("disk", "home")
+	d.add("exec", "")
+	d.skip("gone")
+	var buf bytes.Buffer
+	if _, err := d.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `# Imported from test by ` + "`swaystats import`" + `. Check the notes below.
+
+[modules.cpu]
+
+[modules.disk.root]
+
+[modules.disk.home]
+
+[modules.disk.home_2]
+
+[modules.exec.exec]
+
+# gone
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestInstanceName(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"/", "root"},
+		{"/home", "home"},
+		{"/mnt/data disk", "mnt_data_disk"},
+		{"Europe/Berlin", "Europe_Berlin"},
+		{"wlan-0", "wlan-0"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := instanceName(tt.in); got != tt.want {
+			t.Errorf("instanceName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplitFormat(t *testing.T) {
+	tests := []struct{ in, prefix, rest string }{
+		{"CPU %usage", "CPU", "%usage"},
+		{"%used / %total", "", "%used / %total"},
+		{"static", "static", ""},
+		{"  RAM  %used", "RAM", "%used"},
+	}
+	for _, tt := range tests {
+		prefix, rest := splitFormat(tt.in)
+		if prefix != tt.prefix || rest != tt.rest {
+			t.Errorf("splitFormat(%q) = %q, %q; want %q, %q", tt.in, prefix, rest, tt.prefix, tt.rest)
+		}
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"90", 90, true},
+		{"10%", 10, true},
+		{" 5 ", 5, true},
+		{"0", 0, true},
+		{"101", 101, false},
+		{"-1", -1, false},
+		{"1G", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := percent(tt.in)
+		if ok != tt.ok || ok && got != tt.want {
+			t.Errorf("percent(%q) = %d, %v; want %d, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTOMLValue(t *testing.T) {
+	tests := []struct {
+		v    any
+		want string
+	}{
+		{"plain", `"plain"`},
+		{`a "b" <c> & \`, `"a \"b\" <c> & \\"`},
+		{"tab\tnl\n", `"tab\tnl\n"`},
+		{30, "30"},
+		{false, "false"},
+	}
+	for _, tt := range tests {
+		if got := tomlValue(tt.v); got != tt.want {
+			t.Errorf("tomlValue(%#v) = %s, want %s", tt.v, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package notify sends freedesktop desktop notifications over the D-Bus
+// session bus when providers publish severity transitions.
+package notify
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"swaystats/config"
+	"swaystats/events"
+	"swaystats/theme"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	dest = "org.freedesktop.Notifications"
+	path = dbus.ObjectPath("/org/freedesktop/Notifications")
+)
+
+// urgency hint values from the notification spec.
+const (
+	urgencyNormal   byte = 1
+	urgencyCritical byte = 2
+)
+
+type key struct{ module, instance string }
+
+// sent remembers the last bubble shown for one block so repeats replace it.
+type sent struct {
+	id    uint32
+	level theme.Severity // level it announced
+	at    time.Time
+	open  bool // not yet closed by a recovery
+}
+
+// Notifier turns severity changes into notifications. The bus is connected
+// lazily and reconnected after failures, so a missing notification daemon
+// only produces log lines.
+type Notifier struct {
+	cfg  func() *config.Config
+	dial func() (*dbus.Conn, error)
+	conn *dbus.Conn
+	sent map[key]sent
+}
+
+// New returns a Notifier on the session bus (honoring DBUS_SESSION_BUS_ADDRESS).
+func New(cfg func() *config.Config) *Notifier {
+	return NewWithBus(cfg, SessionBus)
+}
+
+// NewWithBus returns a Notifier that connects with dial, which must return an
+// authenticated connection; tests use it to talk to a private bus.
+func NewWithBus(cfg func() *config.Config, dial func() (*dbus.Conn, error)) *Notifier {
+	return &Notifier{cfg: cfg, dial: dial, sent: map[key]sent{}}
+}
+
+// SessionBus opens a private connection to the session bus.
+func SessionBus() (*dbus.Conn, error) {
+	conn, err := dbus.SessionBusPrivate()
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Auth(nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.Hello(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Run handles events from ch until it is closed.
+func Run(ch <-chan events.SeverityChange, cfg func() *config.Config) {
+	n := New(cfg)
+	for e := range ch {
+		n.Handle(e)
+	}
+}
+
+// Handle notifies when e enters the block's configured notify level (or
+// escalates past it) and closes the bubble when the block recovers below it.
+func (n *Notifier) Handle(e events.SeverityChange) {
+	cfg := n.cfg()
+	t, ok := cfg.ThresholdsFor(config.ModuleRef{Kind: e.Module, Instance: e.Instance})
+	if !ok {
+		return
+	}
+	min, ok := theme.ParseSeverity(t.Notify)
+	if !ok || min == theme.SeverityNormal {
+		return
+	}
+	k := key{e.Module, e.Instance}
+	prev, shown := n.sent[k]
+	if e.To < min {
+		if shown && prev.open {
+			n.close(prev.id)
+			prev.open = false
+			n.sent[k] = prev
+		}
+		return
+	}
+	if e.To <= e.From {
+		return // de-escalation that stays at or above the notify level
+	}
+	// Within the interval only an escalation past the last announced level
+	// gets through, so a flapping block cannot flood the desktop.
+	interval := time.Duration(cfg.Notifications.MinIntervalSec) * time.Second
+	if shown && e.To <= prev.level && e.At.Sub(prev.at) < interval {
+		return
+	}
+	var replaces uint32
+	if shown {
+		replaces = prev.id
+	}
+	id, err := n.notify(cfg.Notifications, replaces, e, expand(t.NotifySummary, e), expand(t.NotifyBody, e))
+	if err != nil {
+		slog.Warn("notify failed", "module", e.Module, "instance", e.Instance, "err", err)
+		return
+	}
+	n.sent[k] = sent{id: id, level: e.To, at: e.At, open: true}
+}
+
+func (n *Notifier) notify(nc config.Notifications, replaces uint32, e events.SeverityChange, summary, body string) (uint32, error) {
+	conn, err := n.session()
+	if err != nil {
+		return 0, err
+	}
+	urgency := urgencyNormal
+	if e.To == theme.SeverityDanger {
+		urgency = urgencyCritical
+	}
+	hints := map[string]dbus.Variant{
+		"urgency":  dbus.MakeVariant(urgency),
+		"category": dbus.MakeVariant("device"),
+	}
+	var id uint32
+	call := conn.Object(dest, path).Call(dest+".Notify", 0,
+		nc.AppName, replaces, "", summary, body, []string{}, hints, int32(nc.TimeoutMs))
+	if err := call.Store(&id); err != nil {
+		n.drop()
+		return 0, err
+	}
+	return id, nil
+}
+
+func (n *Notifier) close(id uint32) {
+	if n.conn == nil {
+		return
+	}
+	if err := n.conn.Object(dest, path).Call(dest+".CloseNotification", 0, id).Err; err != nil {
+		slog.Warn("close notification", "id", id, "err", err)
+	}
+}
+
+func (n *Notifier) session() (*dbus.Conn, error) {
+	if n.conn != nil {
+		return n.conn, nil
+	}
+	conn, err := n.dial()
+	if err != nil {
+		return nil, err
+	}
+	n.conn = conn
+	return conn, nil
+}
+
+// drop forgets a connection that may be broken; the next call reconnects.
+func (n *Notifier) drop() {
+	if n.conn != nil && !n.conn.Connected() {
+		n.conn.Close()
+		n.conn = nil
+	}
+}
+
+// expand replaces {module}, {instance}, {level} and {value} in tmpl.
+func expand(tmpl string, e events.SeverityChange) string {
+	r := strings.NewReplacer(
+		"{module}", e.Module,
+		"{instance}", e.Instance,
+		"{level}", e.To.String(),
+		"{value}", fmt.Sprintf("%.0f%%", e.Value),
+	)
+	return strings.Join(strings.Fields(r.Replace(tmpl)), " ")
+}
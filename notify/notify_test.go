@@ -0,0 +1,217 @@
+package notify
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"swaystats/config"
+	"swaystats/events"
+	"swaystats/theme"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:dir=DIR</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*"/>
+    <allow receive_sender="*"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// privateBus starts a dbus-daemon for the test and returns its address.
+func privateBus(t *testing.T) string {
+	t.Helper()
+	bin, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not installed")
+	}
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(conf, []byte(strings.ReplaceAll(busConfig, "DIR", dir)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(bin, "--config-file="+conf, "--nofork", "--print-address")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	addr, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		t.Fatalf("dbus-daemon address: %v", err)
+	}
+	return strings.TrimSpace(addr)
+}
+
+// fakeServer records the calls of the notification interface.
+type fakeServer struct {
+	mu      sync.Mutex
+	next    uint32
+	notices []notice
+	closed  []uint32
+}
+
+type notice struct {
+	replaces      uint32
+	summary, body string
+	urgency       byte
+	appName       string
+	timeoutMs     int32
+	id            uint32
+}
+
+func (f *fakeServer) Notify(app string, replaces uint32, icon, summary, body string, actions []string, hints map[string]dbus.Variant, timeout int32) (uint32, *dbus.Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := replaces
+	if id == 0 {
+		f.next++
+		id = f.next
+	}
+	urgency, _ := hints["urgency"].Value().(byte)
+	f.notices = append(f.notices, notice{replaces, summary, body, urgency, app, timeout, id})
+	return id, nil
+}
+
+func (f *fakeServer) CloseNotification(id uint32) *dbus.Error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = append(f.closed, id)
+	return nil
+}
+
+func (f *fakeServer) snapshot() ([]notice, []uint32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]notice(nil), f.notices...), append([]uint32(nil), f.closed...)
+}
+
+// serve exports a fakeServer as org.freedesktop.Notifications on the bus.
+func serve(t *testing.T, addr string) *fakeServer {
+	t.Helper()
+	conn, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	f := &fakeServer{}
+	if err := conn.Export(f, path, dest); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := conn.RequestName(dest, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("request name: %v %v", reply, err)
+	}
+	return f
+}
+
+func loadConfig(t *testing.T, body string) *config.Config {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func change(from, to theme.Severity, value float64, at time.Time) events.SeverityChange {
+	return events.SeverityChange{Module: "cpu", From: from, To: to, Value: value, At: at}
+}
+
+func TestNotifierPrivateBus(t *testing.T) {
+	addr := privateBus(t)
+	srv := serve(t, addr)
+	cfg := loadConfig(t, `
+[notifications]
+app_name = "test"
+min_interval_sec = 60
+timeout_ms = 5000
+
+[modules.cpu]
+notify = "warn"
+notify_summary = "{module} {level}"
+notify_body = "at {value}"
+`)
+	n := NewWithBus(func() *config.Config { return cfg }, func() (*dbus.Conn, error) { return dbus.Connect(addr) })
+	t.Cleanup(func() {
+		if n.conn != nil {
+			n.conn.Close()
+		}
+	})
+
+	t0 := time.Now()
+	n.Handle(change(theme.SeverityNormal, theme.SeverityWarn, 81, t0))
+	n.Handle(change(theme.SeverityWarn, theme.SeverityDanger, 96, t0.Add(time.Second)))   // escalation replaces
+	n.Handle(change(theme.SeverityDanger, theme.SeverityWarn, 85, t0.Add(2*time.Second))) // de-escalation: silent
+	n.Handle(change(theme.SeverityWarn, theme.SeverityNormal, 40, t0.Add(3*time.Second))) // recovery closes
+	n.Handle(change(theme.SeverityNormal, theme.SeverityWarn, 82, t0.Add(4*time.Second))) // within interval: suppressed
+	n.Handle(change(theme.SeverityNormal, theme.SeverityWarn, 83, t0.Add(2*time.Minute)))
+
+	notices, closed := srv.snapshot()
+	want := []notice{
+		{replaces: 0, summary: "cpu warn", body: "at 81%", urgency: urgencyNormal, appName: "test", timeoutMs: 5000, id: 1},
+		{replaces: 1, summary: "cpu danger", body: "at 96%", urgency: urgencyCritical, appName: "test", timeoutMs: 5000, id: 1},
+		{replaces: 1, summary: "cpu warn", body: "at 83%", urgency: urgencyNormal, appName: "test", timeoutMs: 5000, id: 1},
+	}
+	if len(notices) != len(want) {
+		t.Fatalf("got %d notifications %+v, want %d", len(notices), notices, len(want))
+	}
+	for i := range want {
+		if notices[i] != want[i] {
+			t.Errorf("notification %d = %+v, want %+v", i, notices[i], want[i])
+		}
+	}
+	if len(closed) != 1 || closed[0] != 1 {
+		t.Errorf("closed = %v, want [1]", closed)
+	}
+}
+
+func TestNotifierOff(t *testing.T) {
+	cfg := loadConfig(t, "[modules.cpu]\n")
+	dialed := false
+	n := NewWithBus(func() *config.Config { return cfg }, func() (*dbus.Conn, error) {
+		dialed = true
+		return nil, os.ErrNotExist
+	})
+	n.Handle(change(theme.SeverityNormal, theme.SeverityDanger, 99, time.Now()))
+	if dialed {
+		t.Error("notify = off connected to the bus")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	e := events.SeverityChange{Module: "disk", Instance: "home", To: theme.SeverityDanger, Value: 91.6}
+	tests := []struct{ tmpl, want string }{
+		{"{module} {level}", "disk danger"},
+		{"{module} {instance} at {value}", "disk home at 92%"},
+		{"{module}  {missing}", "disk {missing}"},
+	}
+	for _, tt := range tests {
+		if got := expand(tt.tmpl, e); got != tt.want {
+			t.Errorf("expand(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
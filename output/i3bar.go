@@ -0,0 +1,34 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"swaystats/blocks"
+)
+
+// i3bar speaks the swaybar/i3bar JSON protocol: a header, then an endless
+// array of rows where every row after the first is comma-prefixed.
+type i3bar struct {
+	clicks bool
+	buf    bytes.Buffer
+}
+
+func (b *i3bar) Start(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "{\"version\":1,\"click_events\":%t}\n[\n[]\n", b.clicks)
+	return err
+}
+
+func (b *i3bar) Row(w io.Writer, row []blocks.Block) error {
+	b.buf.Reset()
+	b.buf.WriteByte(',')
+	if err := json.NewEncoder(&b.buf).Encode(row); err != nil {
+		return err
+	}
+	_, err := w.Write(b.buf.Bytes())
+	return err
+}
+
+func (b *i3bar) Clicks() bool { return b.clicks }
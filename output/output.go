@@ -0,0 +1,76 @@
+// Package output renders rows of blocks for a status bar or terminal.
+// i3bar is the default; the other backends let the same config drive
+// waybar, lemonbar/polybar, tmux or a plain terminal.
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"swaystats/blocks"
+	"swaystats/theme"
+)
+
+// Backend formats rows. Start is called once before the first Row.
+type Backend interface {
+	Start(w io.Writer) error
+	Row(w io.Writer, row []blocks.Block) error
+	// Clicks reports whether the bar sends click events on stdin.
+	Clicks() bool
+}
+
+// Names lists the available backends.
+func Names() []string {
+	names := make([]string, 0, len(backends))
+	for n := range backends {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Options tune a backend.
+type Options struct {
+	Separator string // joins blocks for single-line backends
+	NoClicks  bool   // i3bar: do not ask the bar for click events
+}
+
+var backends = map[string]func(o Options) Backend{
+	"i3bar":    func(o Options) Backend { return &i3bar{clicks: !o.NoClicks} },
+	"waybar":   func(o Options) Backend { return &waybar{sep: o.Separator} },
+	"lemonbar": func(o Options) Backend { return &text{sep: o.Separator, color: lemonColor, escape: lemonEscaper} },
+	"tmux":     func(o Options) Backend { return &text{sep: o.Separator, color: tmuxColor, escape: tmuxEscaper} },
+	"plain":    func(o Options) Backend { return &text{sep: o.Separator, color: ansiColor} },
+}
+
+// New returns the named backend.
+func New(name string, o Options) (Backend, error) {
+	if name == "polybar" { // same formatting tags as lemonbar
+		name = "lemonbar"
+	}
+	mk, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown output %q (want one of %s)", name, strings.Join(Names(), ", "))
+	}
+	return mk(o), nil
+}
+
+// worst returns the highest severity in row.
+func worst(row []blocks.Block) theme.Severity {
+	sev := theme.SeverityNormal
+	for _, b := range row {
+		sev = max(sev, b.Severity)
+	}
+	return sev
+}
+
+// writeLine writes buf plus a newline in a single call, so a reader never
+// sees a partial row.
+func writeLine(w io.Writer, buf *bytes.Buffer) error {
+	buf.WriteByte('\n')
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,69 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"swaystats/blocks"
+	"swaystats/metrics"
+)
+
+// State is the full machine-readable snapshot written by `once --json` and
+// `watch --json`, one object per line.
+type State struct {
+	Time    time.Time     `json:"time"`
+	Blocks  []StateBlock  `json:"blocks"`
+	Metrics []StateMetric `json:"metrics,omitempty"`
+}
+
+// StateBlock is a block with its severity spelled out.
+type StateBlock struct {
+	Name     string `json:"name"`
+	Instance string `json:"instance,omitempty"`
+	Text     string `json:"text"`
+	Color    string `json:"color,omitempty"`
+	Urgent   bool   `json:"urgent,omitempty"`
+	Severity string `json:"severity"`
+}
+
+// StateMetric is one exported sample.
+type StateMetric struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+// WriteState writes one State line.
+func WriteState(w io.Writer, at time.Time, row []blocks.Block, samples []metrics.Sample) error {
+	st := State{Time: at, Blocks: make([]StateBlock, 0, len(row))}
+	for _, b := range row {
+		st.Blocks = append(st.Blocks, StateBlock{
+			Name:     b.Name,
+			Instance: b.Instance,
+			Text:     b.Text(),
+			Color:    b.Color,
+			Urgent:   b.Urgent,
+			Severity: b.Severity.String(),
+		})
+	}
+	for _, s := range samples {
+		m := StateMetric{Name: s.Name, Value: s.Value}
+		for _, l := range s.Labels {
+			if l.Value == "" {
+				continue
+			}
+			if m.Labels == nil {
+				m.Labels = map[string]string{}
+			}
+			m.Labels[l.Name] = l.Value
+		}
+		st.Metrics = append(st.Metrics, m)
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,72 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"swaystats/blocks"
+)
+
+// text renders a row as one line of blocks joined by sep, coloring each
+// block with the backend's markup.
+type text struct {
+	sep    string
+	color  func(buf *bytes.Buffer, hex, s string)
+	escape *strings.Replacer // nil: no escaping
+	buf    bytes.Buffer
+}
+
+func (b *text) Start(io.Writer) error { return nil }
+
+func (b *text) Row(w io.Writer, row []blocks.Block) error {
+	b.buf.Reset()
+	for i, blk := range row {
+		if i > 0 {
+			b.buf.WriteString(b.sep)
+		}
+		s := blk.Text()
+		if b.escape != nil {
+			s = b.escape.Replace(s)
+		}
+		if blk.Color == "" {
+			b.buf.WriteString(s)
+			continue
+		}
+		b.color(&b.buf, blk.Color, s)
+	}
+	return writeLine(w, &b.buf)
+}
+
+func (b *text) Clicks() bool { return false }
+
+var (
+	// lemonbar has no escape for "%{", so it is broken up instead; a lone % prints as is.
+	lemonEscaper = strings.NewReplacer("%{", "% {")
+	tmuxEscaper  = strings.NewReplacer("#", "##")
+)
+
+// lemonColor uses lemonbar/polybar %{F} tags.
+func lemonColor(buf *bytes.Buffer, hex, s string) {
+	fmt.Fprintf(buf, "%%{F%s}%s%%{F-}", hex, s)
+}
+
+// tmuxColor uses tmux style directives for status-left/status-right.
+func tmuxColor(buf *bytes.Buffer, hex, s string) {
+	fmt.Fprintf(buf, "#[fg=%s]%s#[default]", hex, s)
+}
+
+// ansiColor uses 24-bit ANSI escapes; colors that are not #rrggbb are dropped.
+func ansiColor(buf *bytes.Buffer, hex, s string) {
+	var r, g, b uint8
+	if len(hex) != 7 || hex[0] != '#' {
+		buf.WriteString(s)
+		return
+	}
+	if _, err := fmt.Sscanf(hex[1:], "%02x%02x%02x", &r, &g, &b); err != nil {
+		buf.WriteString(s)
+		return
+	}
+	fmt.Fprintf(buf, "\x1b[38;2;%d;%d;%dm%s\x1b[0m", r, g, b, s)
+}
@@ -0,0 +1,66 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"html"
+	"io"
+	"strings"
+
+	"swaystats/blocks"
+)
+
+// waybar emits one custom-module JSON object per line (return-type = "json").
+// Text uses Pango spans for colors; class is the worst severity in the row.
+type waybar struct {
+	sep string
+	buf bytes.Buffer
+}
+
+type waybarRow struct {
+	Text       string `json:"text"`
+	Tooltip    string `json:"tooltip,omitempty"`
+	Class      string `json:"class,omitempty"`
+	Percentage int    `json:"percentage,omitempty"`
+}
+
+func (b *waybar) Start(io.Writer) error { return nil }
+
+func (b *waybar) Row(w io.Writer, row []blocks.Block) error {
+	var out waybarRow
+	texts := make([]string, 0, len(row))
+	tips := make([]string, 0, len(row))
+	for _, blk := range row {
+		t := blk.FullText
+		if blk.Markup != "pango" {
+			t = html.EscapeString(t)
+		}
+		tips = append(tips, blockLabel(blk)+": "+t)
+		if blk.Color != "" {
+			t = `<span color="` + blk.Color + `">` + t + `</span>`
+		}
+		texts = append(texts, t)
+		out.Percentage = max(out.Percentage, blk.Percentage)
+	}
+	out.Text = strings.Join(texts, html.EscapeString(b.sep))
+	out.Tooltip = strings.Join(tips, "\n")
+	if sev := worst(row); sev > 0 {
+		out.Class = sev.String()
+	}
+	b.buf.Reset()
+	if err := json.NewEncoder(&b.buf).Encode(out); err != nil {
+		return err
+	}
+	_, err := w.Write(b.buf.Bytes())
+	return err
+}
+
+func (b *waybar) Clicks() bool { return false }
+
+// blockLabel names a block as name or name/instance.
+func blockLabel(b blocks.Block) string {
+	if b.Instance != "" {
+		return b.Name + "/" + b.Instance
+	}
+	return b.Name
+}
@@ -0,0 +1,311 @@
+// Package plugin supervises long-running block plugins: external processes
+// speaking newline-delimited JSON-RPC 2.0 on stdin/stdout.
+//
+//	swaystats → plugin  {"jsonrpc":"2.0","id":1,"method":"init","params":{"name":…,"instance":…,"config":{…}}}
+//	plugin → swaystats  {"jsonrpc":"2.0","method":"update","params":{"full_text":…,"color":…}}  (any time)
+//	swaystats → plugin  {"jsonrpc":"2.0","method":"click","params":{"button":1,…}}
+//	swaystats → plugin  {"jsonrpc":"2.0","method":"shutdown"}  (then stdin is closed)
+//
+// The plugin's stderr is forwarded to the log. A plugin that exits is
+// restarted with exponential backoff.
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"swaystats/clicks"
+)
+
+// Update is the block payload of an "update" notification (i3bar block fields).
+type Update struct {
+	FullText   string `json:"full_text"`
+	ShortText  string `json:"short_text,omitempty"`
+	Color      string `json:"color,omitempty"`
+	Background string `json:"background,omitempty"`
+	Urgent     bool   `json:"urgent,omitempty"`
+	Markup     string `json:"markup,omitempty"`
+}
+
+// InitParams are sent with the "init" request.
+type InitParams struct {
+	Name     string         `json:"name"`
+	Instance string         `json:"instance,omitempty"`
+	Config   map[string]any `json:"config"`
+}
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+const (
+	minBackoff  = time.Second
+	stableAfter = 30 * time.Second // a run this long resets the backoff
+	stopGrace   = time.Second      // time to exit after shutdown before being killed
+)
+
+// Supervisor runs one plugin process and keeps it running.
+type Supervisor struct {
+	name, instance string
+	command        string
+	init           InitParams
+	maxBackoff     time.Duration
+
+	mu       sync.Mutex
+	latest   Update
+	version  uint64 // bumped on every update
+	running  bool
+	out      chan []byte // to the current process' stdin; nil when not running
+	updated  chan struct{}
+	stopped  chan struct{}
+	done     chan struct{} // closed when supervise returns
+	stopOnce sync.Once
+}
+
+// Start launches command (via sh -c) and supervises it until Stop.
+func Start(name, instance, command string, config map[string]any, maxBackoff time.Duration) *Supervisor {
+	s := &Supervisor{
+		name:       name,
+		instance:   instance,
+		command:    command,
+		init:       InitParams{Name: name, Instance: instance, Config: config},
+		maxBackoff: max(maxBackoff, minBackoff),
+		updated:    make(chan struct{}),
+		stopped:    make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go s.supervise()
+	return s
+}
+
+// Latest returns the last update, its version (0 before the first update)
+// and whether the process is currently running.
+func (s *Supervisor) Latest() (Update, uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latest, s.version, s.running
+}
+
+// WaitUpdate waits up to timeout for the first update.
+func (s *Supervisor) WaitUpdate(timeout time.Duration) bool {
+	select {
+	case <-s.updated:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Click forwards a click event; it is dropped if the plugin is not keeping up.
+func (s *Supervisor) Click(c clicks.Click) {
+	s.notify("click", c)
+}
+
+// Stop asks the plugin to shut down, kills it (with everything it started)
+// after a grace period and stops restarting it. It returns once the process
+// is gone.
+func (s *Supervisor) Stop() {
+	s.stopOnce.Do(func() {
+		s.notify("shutdown", nil)
+		close(s.stopped)
+	})
+	<-s.done
+}
+
+func (s *Supervisor) log() *slog.Logger {
+	return slog.With("module", s.name, "instance", s.instance)
+}
+
+func (s *Supervisor) supervise() {
+	defer close(s.done)
+	backoff := minBackoff
+	for {
+		start := time.Now()
+		err := s.runOnce()
+		select {
+		case <-s.stopped:
+			s.log().Debug("plugin stopped", "cmd", s.command, "err", err)
+			return
+		default:
+		}
+		if time.Since(start) >= stableAfter {
+			backoff = minBackoff
+		}
+		if err != nil {
+			s.log().Warn("plugin exited", "cmd", s.command, "err", err, "restart_in", backoff)
+		} else {
+			s.log().Warn("plugin exited", "cmd", s.command, "restart_in", backoff)
+		}
+		select {
+		case <-s.stopped:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, s.maxBackoff)
+	}
+}
+
+// runOnce runs the process until it exits or Stop is called.
+func (s *Supervisor) runOnce() error {
+	cmd := exec.Command("sh", "-c", s.command)
+	cmd.Env = append(os.Environ(), "SWAYSTATS_PLUGIN="+s.name, "SWAYSTATS_INSTANCE="+s.instance)
+	// Own process group, so a kill reaches the plugin and not just the shell.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	out := make(chan []byte, 16)
+	s.mu.Lock()
+	s.running, s.out = true, out
+	s.mu.Unlock()
+	go s.writeLoop(stdin, out)
+	go s.logStderr(stderr)
+	s.request(1, "init", s.init)
+
+	exited := make(chan error, 1)
+	go func() {
+		s.readLoop(stdout)
+		exited <- cmd.Wait()
+	}()
+	// detach stops further sends; the write loop flushes what is queued
+	// (e.g. shutdown) and then closes the plugin's stdin.
+	detach := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.out != nil {
+			s.out = nil
+			close(out)
+		}
+	}
+	var werr error
+	select {
+	case werr = <-exited:
+	case <-s.stopped:
+		detach()
+		select {
+		case werr = <-exited:
+		case <-time.After(stopGrace):
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			werr = <-exited
+		}
+	}
+	detach()
+	s.mu.Lock()
+	s.running = false
+	s.mu.Unlock()
+	return werr
+}
+
+func (s *Supervisor) writeLoop(w io.WriteCloser, out <-chan []byte) {
+	defer w.Close()
+	for line := range out {
+		if _, err := w.Write(line); err != nil {
+			for range out { // drain until the process is reaped
+			}
+			return
+		}
+	}
+}
+
+func (s *Supervisor) readLoop(r io.Reader) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 4096), 1024*1024)
+	for sc.Scan() {
+		var m message
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			s.log().Warn("plugin sent invalid JSON", "err", err)
+			continue
+		}
+		switch {
+		case m.Error != nil:
+			s.log().Warn("plugin error response", "code", m.Error.Code, "message", m.Error.Message)
+		case m.Method == "update":
+			var u Update
+			if err := json.Unmarshal(m.Params, &u); err != nil {
+				s.log().Warn("plugin update", "err", err)
+				continue
+			}
+			s.mu.Lock()
+			first := s.version == 0
+			s.latest = u
+			s.version++
+			s.mu.Unlock()
+			if first {
+				close(s.updated)
+			}
+		case m.Method != "":
+			s.log().Debug("plugin sent unknown method", "method", m.Method)
+		}
+	}
+}
+
+func (s *Supervisor) logStderr(r io.Reader) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		s.log().Info("plugin stderr", "line", sc.Text())
+	}
+}
+
+func (s *Supervisor) request(id int64, method string, params any) {
+	s.send(message{JSONRPC: "2.0", ID: &id, Method: method}, params)
+}
+
+func (s *Supervisor) notify(method string, params any) {
+	s.send(message{JSONRPC: "2.0", Method: method}, params)
+}
+
+// send queues a message for the running process without blocking.
+func (s *Supervisor) send(m message, params any) {
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			s.log().Warn("encode plugin message", "method", m.Method, "err", err)
+			return
+		}
+		m.Params = raw
+	}
+	line, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.out == nil {
+		return
+	}
+	select {
+	case s.out <- line:
+	default:
+		s.log().Debug("plugin not reading stdin; message dropped", "method", m.Method)
+	}
+}
@@ -0,0 +1,171 @@
+// Package profile selects the active config profile: the one chosen manually
+// with `swaystats profile <name>` (kept in a state file, so it survives
+// restarts), else the first profile in file order whose `when` expression
+// holds, else none.
+//
+// Conditions see the system facts of expr.System plus the sway outputs:
+//
+//	outputs            number of active outputs
+//	output("DP-1")     whether an output of that name (or "make model") is active
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"swaystats/config"
+	"swaystats/expr"
+	"swaystats/sway"
+)
+
+// Auto is the manual choice that re-enables automatic selection.
+const Auto = "auto"
+
+// Selector evaluates profile conditions; it remembers which ones failed so
+// each problem is logged once.
+type Selector struct {
+	rules  map[string]*expr.Expr // by source; nil if invalid
+	failed map[string]bool
+}
+
+func NewSelector() *Selector {
+	return &Selector{rules: map[string]*expr.Expr{}, failed: map[string]bool{}}
+}
+
+// Select returns the active profile of cfg ("" for none) and whether it was
+// chosen manually.
+func (s *Selector) Select(cfg *config.Config) (string, bool) {
+	if name := Manual(); name != "" {
+		if _, ok := cfg.Profiles[name]; ok {
+			return name, true
+		}
+		if !s.failed["manual:"+name] {
+			slog.Warn("manual profile not in config; selecting automatically", "profile", name)
+			s.failed["manual:"+name] = true
+		}
+	}
+	env := newEnv(time.Now())
+	for _, name := range cfg.ProfileNames() {
+		if s.matches(name, cfg.Profiles[name].When, env) {
+			return name, false
+		}
+	}
+	return "", false
+}
+
+// matches evaluates one profile's condition; errors count as false.
+func (s *Selector) matches(name, src string, env *expr.Env) bool {
+	if strings.TrimSpace(src) == "" {
+		return false
+	}
+	rule, ok := s.rules[src]
+	if !ok {
+		var err error
+		if rule, err = expr.Parse(src); err != nil {
+			slog.Warn("profile condition ignored", "profile", name, "when", src, "err", err)
+		}
+		s.rules[src] = rule
+	}
+	if rule == nil {
+		return false
+	}
+	match, err := rule.Bool(env)
+	if err != nil {
+		if !s.failed[src] {
+			slog.Warn("profile condition failed", "profile", name, "when", src, "err", err)
+			s.failed[src] = true
+		}
+		return false
+	}
+	s.failed[src] = false
+	return match
+}
+
+// newEnv adds the sway outputs (queried once, on first use) to the system facts.
+func newEnv(now time.Time) *expr.Env {
+	env := expr.System(now)
+	var (
+		outs    []sway.Output
+		outsErr error
+		queried bool
+	)
+	active := func() ([]sway.Output, error) {
+		if !queried {
+			queried = true
+			all, err := sway.Outputs()
+			for _, o := range all {
+				if o.Active {
+					outs = append(outs, o)
+				}
+			}
+			outsErr = err
+		}
+		return outs, outsErr
+	}
+	env.Vars["outputs"] = func() expr.Value {
+		outs, _ := active()
+		return float64(len(outs))
+	}
+	env.Funcs["output"] = func(args []expr.Value) (expr.Value, error) {
+		name, err := expr.StringArg("output", args)
+		if err != nil {
+			return nil, err
+		}
+		outs, err := active()
+		if err != nil {
+			return nil, fmt.Errorf("output: %w", err)
+		}
+		for _, o := range outs {
+			if o.Name == name || o.Make+" "+o.Model == name {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return env
+}
+
+// statePath is where the manual choice is kept.
+func statePath() string {
+	dir := config.StateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "profile")
+}
+
+// Manual returns the manually chosen profile, or "" for automatic selection.
+func Manual() string {
+	path := statePath()
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// SetManual records a manual choice; "" or Auto clears it.
+func SetManual(name string) error {
+	path := statePath()
+	if path == "" {
+		return errors.New("no state directory")
+	}
+	if name == "" || name == Auto {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(name+"\n"), 0o644)
+}
@@ -0,0 +1,192 @@
+// Package stats records how expensive each provider is to refresh and how
+// often the bar is redrawn, so the footprint of swaystats stays visible.
+package stats
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Provider holds the counters of one provider instance.
+type Provider struct {
+	Module   string
+	Instance string
+	Calls    int64         // MaybeRefresh calls
+	Changes  int64         // calls that reported a changed block
+	Total    time.Duration // summed refresh time
+	Max      time.Duration
+	Last     time.Duration
+	Slow     int64 // calls at or above the slow threshold
+}
+
+// Mean is the average refresh time.
+func (p Provider) Mean() time.Duration {
+	if p.Calls == 0 {
+		return 0
+	}
+	return p.Total / time.Duration(p.Calls)
+}
+
+// Label names the provider as module or module/instance.
+func (p Provider) Label() string {
+	if p.Instance != "" {
+		return p.Module + "/" + p.Instance
+	}
+	return p.Module
+}
+
+type key struct{ module, instance string }
+
+// Recorder accumulates statistics. Observe and Row are called from the render
+// loop; Snapshot may be called from any goroutine.
+type Recorder struct {
+	mu        sync.Mutex
+	start     time.Time
+	slow      time.Duration // 0 disables slow warnings
+	providers map[key]*Provider
+	order     []key
+	rows      int64
+	rowSecs   [60]int64 // unix second of each bucket
+	rowCounts [60]int64 // rows emitted in that second
+	warned    map[key]time.Time
+}
+
+// Default is the recorder used by the render loop and the debug block.
+var Default = New()
+
+func New() *Recorder {
+	return &Recorder{start: time.Now(), slow: 50 * time.Millisecond, providers: map[key]*Provider{}, warned: map[key]time.Time{}}
+}
+
+// SetSlow sets the duration from which a refresh counts as slow (0 disables).
+func (r *Recorder) SetSlow(d time.Duration) {
+	r.mu.Lock()
+	r.slow = d
+	r.mu.Unlock()
+}
+
+// Observe records one MaybeRefresh call. Slow calls are logged at most once a
+// minute per provider.
+func (r *Recorder) Observe(module, instance string, took time.Duration, changed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k := key{module, instance}
+	p := r.providers[k]
+	if p == nil {
+		p = &Provider{Module: module, Instance: instance}
+		r.providers[k] = p
+		r.order = append(r.order, k)
+	}
+	p.Calls++
+	if changed {
+		p.Changes++
+	}
+	p.Total += took
+	p.Last = took
+	p.Max = max(p.Max, took)
+	if r.slow > 0 && took >= r.slow {
+		p.Slow++
+		now := time.Now()
+		if now.Sub(r.warned[k]) >= time.Minute {
+			r.warned[k] = now
+			slog.Warn("slow provider", "module", module, "instance", instance, "took", took, "slow", r.slow)
+		}
+	}
+}
+
+// Row records one emitted row.
+func (r *Recorder) Row(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rows++
+	sec := now.Unix()
+	i := sec % 60
+	if r.rowSecs[i] != sec {
+		r.rowSecs[i], r.rowCounts[i] = sec, 0
+	}
+	r.rowCounts[i]++
+}
+
+// Snapshot is a copy of the recorder's state.
+type Snapshot struct {
+	Uptime       time.Duration
+	Rows         int64
+	RowsLastMin  int64
+	Providers    []Provider // in first-seen order
+	SlowestIndex int        // index into Providers by mean time, -1 if none
+}
+
+func (r *Recorder) Snapshot(now time.Time) Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := Snapshot{Uptime: now.Sub(r.start), Rows: r.rows, SlowestIndex: -1}
+	for i, sec := range r.rowSecs {
+		if now.Unix()-sec < 60 {
+			s.RowsLastMin += r.rowCounts[i]
+		}
+	}
+	for _, k := range r.order {
+		p := *r.providers[k]
+		if s.SlowestIndex < 0 || p.Mean() > s.Providers[s.SlowestIndex].Mean() {
+			s.SlowestIndex = len(s.Providers)
+		}
+		s.Providers = append(s.Providers, p)
+	}
+	return s
+}
+
+// Forget drops providers not in keep (called after a config reload rebuilt
+// the provider set), so the stats only describe what is running.
+func (r *Recorder) Forget(keep func(module, instance string) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	order := r.order[:0]
+	for _, k := range r.order {
+		if keep(k.module, k.instance) {
+			order = append(order, k)
+			continue
+		}
+		delete(r.providers, k)
+		delete(r.warned, k)
+	}
+	r.order = order
+}
+
+// Log writes the snapshot to the default logger, one line per provider,
+// slowest first.
+func (s Snapshot) Log() {
+	slog.Info("stats", "uptime", s.Uptime.Round(time.Second), "rows", s.Rows, "rows_per_min", s.RowsLastMin)
+	ps := append([]Provider(nil), s.Providers...)
+	sort.SliceStable(ps, func(i, j int) bool { return ps[i].Mean() > ps[j].Mean() })
+	for _, p := range ps {
+		slog.Info("stats provider",
+			"module", p.Module, "instance", p.Instance,
+			"calls", p.Calls, "changes", p.Changes,
+			"mean", p.Mean(), "max", p.Max, "last", p.Last, "slow", p.Slow)
+	}
+}
+
+// Summary is a short one-line description for the debug block.
+func (s Snapshot) Summary() string {
+	out := fmt.Sprintf("%d rows/min", s.RowsLastMin)
+	if s.SlowestIndex >= 0 {
+		p := s.Providers[s.SlowestIndex]
+		out += fmt.Sprintf(" slowest %s %s", p.Label(), formatDuration(p.Mean()))
+	}
+	return out
+}
+
+// formatDuration renders d compactly: 850µs, 1.2ms, 3.4s.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Millisecond:
+		return fmt.Sprintf("%dµs", d.Microseconds())
+	case d < time.Second:
+		return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+	default:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	}
+}
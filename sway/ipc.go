@@ -0,0 +1,87 @@
+// Package sway is a minimal client for the sway (i3) IPC socket.
+package sway
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	magic          = "i3-ipc"
+	msgGetOutputs  = 3
+	requestTimeout = time.Second
+)
+
+// Output is one entry of a get_outputs reply.
+type Output struct {
+	Name   string `json:"name"`
+	Make   string `json:"make"`
+	Model  string `json:"model"`
+	Serial string `json:"serial"`
+	Active bool   `json:"active"`
+}
+
+// Outputs returns the outputs known to sway (active or not).
+func Outputs() ([]Output, error) {
+	var outs []Output
+	if err := request(msgGetOutputs, nil, &outs); err != nil {
+		return nil, err
+	}
+	return outs, nil
+}
+
+// socketPath returns $SWAYSOCK, falling back to $I3SOCK.
+func socketPath() (string, error) {
+	for _, env := range []string{"SWAYSOCK", "I3SOCK"} {
+		if p := os.Getenv(env); p != "" {
+			return p, nil
+		}
+	}
+	return "", errors.New("SWAYSOCK not set")
+}
+
+// request sends one message and decodes the JSON reply into v.
+func request(typ uint32, payload []byte, v any) error {
+	path, err := socketPath()
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("unix", path, requestTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	msg := make([]byte, 0, len(magic)+8+len(payload))
+	msg = append(msg, magic...)
+	msg = binary.LittleEndian.AppendUint32(msg, uint32(len(payload)))
+	msg = binary.LittleEndian.AppendUint32(msg, typ)
+	msg = append(msg, payload...)
+	if _, err := conn.Write(msg); err != nil {
+		return err
+	}
+
+	header := make([]byte, len(magic)+8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if string(header[:len(magic)]) != magic {
+		return errors.New("bad reply magic")
+	}
+	size := binary.LittleEndian.Uint32(header[len(magic):])
+	if got := binary.LittleEndian.Uint32(header[len(magic)+4:]); got != typ {
+		return fmt.Errorf("reply type %d, want %d", got, typ)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
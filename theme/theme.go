@@ -34,6 +34,30 @@ const (
 	SeverityDanger
 )
 
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarn:
+		return "warn"
+	case SeverityDanger:
+		return "danger"
+	default:
+		return "normal"
+	}
+}
+
+// ParseSeverity maps "warn"/"danger" (or "normal") to a Severity.
+func ParseSeverity(s string) (Severity, bool) {
+	switch s {
+	case "normal":
+		return SeverityNormal, true
+	case "warn":
+		return SeverityWarn, true
+	case "danger":
+		return SeverityDanger, true
+	}
+	return SeverityNormal, false
+}
+
 // ColorFor returns the hex color and true if severity maps to a color.
 func ColorFor(sev Severity) (string, bool) {
 	switch sev {
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// watchDebounce is how long the config must be quiet before a reload,
+	// so editors writing in several steps cause a single reload.
+	watchDebounce = 150 * time.Millisecond
+	// watchResync is how often the watch set is recomputed: it picks up
+	// directories that were missing or recreated and retargeted symlinks.
+	watchResync = 2 * time.Second
+)
+
+// configWatcher reloads the config when one of its files changes. Parent
+// directories are watched rather than files, so atomic replaces (write to a
+// temporary file, rename over) and files appearing later are seen. For
+// symlinked files (stow, home-manager) the link and its target are watched.
+type configWatcher struct {
+	w      *fsnotify.Watcher
+	paths  func() []string // files and glob patterns to track
+	reload func()
+
+	targets []string          // paths and their resolved symlink targets
+	links   map[string]string // path -> resolved target at the last sync
+	dirs    map[string]bool   // directories currently watched
+}
+
+// startConfigWatcher watches the files and glob patterns returned by paths
+// (re-evaluated after each reload) and calls reload, debounced, on changes.
+func startConfigWatcher(paths func() []string, reload func()) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Error("config watcher init", "err", err)
+		return
+	}
+	cw := &configWatcher{w: w, paths: paths, reload: reload, links: map[string]string{}, dirs: map[string]bool{}}
+	cw.sync()
+	go cw.run()
+}
+
+func (cw *configWatcher) run() {
+	defer cw.w.Close()
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+	resync := time.NewTicker(watchResync)
+	defer resync.Stop()
+	for {
+		select {
+		case ev, ok := <-cw.w.Events:
+			if !ok {
+				return
+			}
+			if cw.dirs[ev.Name] && ev.Has(fsnotify.Remove|fsnotify.Rename) {
+				// A watched directory went away; the resync re-adds it once it is back.
+				delete(cw.dirs, ev.Name)
+				continue
+			}
+			if cw.matches(ev.Name) {
+				debounce.Reset(watchDebounce) // fires once events stop
+			}
+		case err, ok := <-cw.w.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("config watcher", "err", err)
+		case <-debounce.C:
+			cw.reload()
+			cw.sync()
+		case <-resync.C:
+			if cw.sync() {
+				debounce.Reset(watchDebounce)
+			}
+		}
+	}
+}
+
+// sync recomputes the watch set and reports whether something changed that
+// events cannot have announced: a symlink now resolving elsewhere, or a
+// tracked file present in a directory that was not being watched.
+// Directories no longer holding a tracked path are unwatched.
+func (cw *configWatcher) sync() bool {
+	changed := false
+	cw.targets = cw.targets[:0]
+	links := map[string]string{}
+	for _, p := range cw.paths() {
+		cw.targets = append(cw.targets, p)
+		resolved, err := filepath.EvalSymlinks(p)
+		if err != nil || resolved == p {
+			continue
+		}
+		cw.targets = append(cw.targets, resolved)
+		links[p] = resolved
+		if old, ok := cw.links[p]; ok && old != resolved {
+			changed = true
+		}
+	}
+	cw.links = links
+	wanted := map[string]bool{}
+	for _, t := range cw.targets {
+		dir := filepath.Dir(t)
+		wanted[dir] = true
+		if cw.dirs[dir] {
+			continue
+		}
+		if err := cw.w.Add(dir); err != nil {
+			if !os.IsNotExist(err) {
+				slog.Warn("config watcher add", "dir", dir, "err", err)
+			}
+			continue
+		}
+		cw.dirs[dir] = true
+		if _, err := os.Stat(t); err == nil {
+			changed = true
+		}
+	}
+	for dir := range cw.dirs {
+		if !wanted[dir] {
+			_ = cw.w.Remove(dir) // fails if the directory is already gone
+			delete(cw.dirs, dir)
+		}
+	}
+	return changed
+}
+
+// matches reports whether path is a tracked file or matches a tracked pattern.
+func (cw *configWatcher) matches(path string) bool {
+	for _, t := range cw.targets {
+		if t == path {
+			return true
+		}
+		if ok, _ := filepath.Match(t, path); ok {
+			return true
+		}
+	}
+	return false
+}
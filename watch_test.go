@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestConfigWatcherPrunesDirs(t *testing.T) {
+	root := t.TempDir()
+	a, b := filepath.Join(root, "a"), filepath.Join(root, "b")
+	for _, dir := range []string{a, b} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	paths := []string{filepath.Join(a, "config.toml"), filepath.Join(b, "*.toml")}
+	cw := &configWatcher{w: w, paths: func() []string { return paths }, reload: func() {}, links: map[string]string{}, dirs: map[string]bool{}}
+	tests := []struct {
+		name  string
+		paths []string
+		want  []string
+	}{
+		{"initial", paths, []string{a, b}},
+		{"include dropped", paths[:1], []string{a}},
+		{"moved", []string{filepath.Join(b, "config.toml")}, []string{b}},
+		{"missing dir", []string{filepath.Join(root, "c", "config.toml")}, nil},
+	}
+	for _, tt := range tests {
+		paths = tt.paths
+		cw.sync()
+		got := w.WatchList()
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: watching %v, want %v", tt.name, got, tt.want)
+		}
+		if len(cw.dirs) != len(tt.want) {
+			t.Errorf("%s: dirs = %v, want %v", tt.name, cw.dirs, tt.want)
+		}
+	}
+}